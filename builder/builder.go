@@ -2,7 +2,8 @@ package builder
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"strings"
 	"sync"
 
 	"github.com/honmaple/snow/builder/hook"
@@ -14,23 +15,34 @@ import (
 
 type (
 	Builder interface {
-		Build(context.Context) error
+		Read(context.Context) error
+		Write() error
 	}
 	Builders []Builder
 )
 
-func (bs Builders) Build(ctx context.Context) error {
-	var wg sync.WaitGroup
+func (bs Builders) run(f func(Builder) error) error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]string, 0)
+	)
 	for _, b := range bs {
 		wg.Add(1)
 		go func(builder Builder) {
 			defer wg.Done()
-			if err := builder.Build(ctx); err != nil {
-				fmt.Println(err.Error())
+			if err := f(builder); err != nil {
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
 			}
 		}(b)
 	}
 	wg.Wait()
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
 	return nil
 }
 
@@ -41,11 +53,40 @@ func Build(conf config.Config) error {
 		return err
 	}
 	hs := hook.New(conf, th)
+	conf.ResetTransformers()
+
+	hctx := &hook.Context{
+		Config:  conf,
+		Writer:  &conf,
+		Pages:   make(map[string]*page.Context),
+		Statics: make(map[string]*static.Context),
+	}
 
 	bs := make(Builders, 0)
-	for _, langc := range conf.Languages {
-		bs = append(bs, page.NewBuilder(*langc, th, hs.PageHooks()))
-		bs = append(bs, static.NewBuilder(*langc, th, hs.StaticHooks()))
+	for lang, langc := range conf.Languages {
+		pb := page.NewBuilder(*langc, th, hs.PageHooks())
+		sb := static.NewBuilder(*langc, th, hs.StaticHooks())
+
+		hctx.Pages[lang] = pb.Context()
+		hctx.Statics[lang] = sb.Context()
+		bs = append(bs, pb, sb)
+	}
+
+	ctx := context.Background()
+	if err := hs.BeforeBuild(hctx); err != nil {
+		return err
+	}
+	if err := bs.run(func(b Builder) error { return b.Read(ctx) }); err != nil {
+		return err
+	}
+	if err := hs.AfterRead(hctx); err != nil {
+		return err
+	}
+	if err := bs.run(func(b Builder) error { return b.Write() }); err != nil {
+		return err
+	}
+	if err := hs.AfterWrite(hctx); err != nil {
+		return err
 	}
-	return bs.Build(context.Background())
+	return hs.AfterBuild(hctx)
 }
@@ -12,9 +12,13 @@ import (
 	"github.com/honmaple/snow/utils"
 	"github.com/urfave/cli/v2"
 
+	_ "github.com/honmaple/snow/builder/page/markup/asciidoc"
 	_ "github.com/honmaple/snow/builder/page/markup/html"
+	_ "github.com/honmaple/snow/builder/page/markup/ipynb"
 	_ "github.com/honmaple/snow/builder/page/markup/markdown"
 	_ "github.com/honmaple/snow/builder/page/markup/orgmode"
+	_ "github.com/honmaple/snow/builder/page/shortcode"
+	_ "github.com/honmaple/snow/builder/transform"
 
 	_ "github.com/honmaple/snow/builder/hook/assets"
 	_ "github.com/honmaple/snow/builder/hook/encrypt"
@@ -22,6 +26,7 @@ import (
 	_ "github.com/honmaple/snow/builder/hook/pelican"
 	_ "github.com/honmaple/snow/builder/hook/shortcode"
 	_ "github.com/honmaple/snow/builder/hook/sofile"
+	_ "github.com/honmaple/snow/builder/hook/subprocess"
 )
 
 const (
@@ -252,5 +257,6 @@ func Excute() {
 	}
 	if err := app.Run(os.Args); err != nil {
 		fmt.Println(err.Error())
+		os.Exit(1)
 	}
 }
@@ -1,7 +1,12 @@
 package assets
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
+	"sync"
 
 	"github.com/honmaple/snow/builder/hook"
 	"github.com/honmaple/snow/builder/static"
@@ -13,25 +18,45 @@ import (
 type (
 	assets struct {
 		hook.BaseHook
-		conf  config.Config
-		opts  map[string]option
-		hash  map[string]string
-		theme theme.Theme
+		conf    config.Config
+		opts    map[string]option
+		theme   theme.Theme
+		mu      sync.Mutex
+		results map[string]*call
+
+		filtersMu sync.Mutex
+		filters   map[string]Filter
+
+		manifest     string
+		manifestMu   sync.Mutex
+		manifestData map[string]string
 	}
 	option struct {
-		files      []string
-		output     string
-		version    bool
-		filters    []string
-		filterOpts []filterOption
+		files       []string
+		output      string
+		version     bool
+		fingerprint bool
+		filters     []string
+		filterOpts  []FilterOption
+	}
+	result struct {
+		url       string
+		hash      string
+		integrity string
+	}
+	call struct {
+		once sync.Once
+		res  *result
+		err  error
 	}
 )
 
 const (
-	filesTemplate   = "hooks.assets.%s.files"
-	outputTemplate  = "hooks.assets.%s.output"
-	filtersTemplate = "hooks.assets.%s.filters"
-	versionTemplate = "hooks.assets.%s.version"
+	filesTemplate       = "hooks.assets.%s.files"
+	outputTemplate      = "hooks.assets.%s.output"
+	filtersTemplate     = "hooks.assets.%s.filters"
+	versionTemplate     = "hooks.assets.%s.version"
+	fingerprintTemplate = "hooks.assets.%s.fingerprint"
 )
 
 func (self *assets) Name() string {
@@ -39,25 +64,89 @@ func (self *assets) Name() string {
 }
 
 func (self *assets) Statics(statics static.Statics) static.Statics {
-	for name, opt := range self.opts {
-		h, err := self.execute(opt)
-		if err != nil {
+	for name := range self.opts {
+		if _, err := self.named(name); err != nil {
 			self.conf.Log.Errorln("hook assets:", err.Error())
-		} else {
-			self.hash[name] = h
 		}
 	}
 	return statics
 }
 
+// 页面和静态文件同时生成, 配置中的assets只在第一次使用时生成
+func (self *assets) named(name string) (*result, error) {
+	opt, ok := self.opts[name]
+	if !ok {
+		return &result{url: self.conf.GetString(fmt.Sprintf(outputTemplate, name))}, nil
+	}
+	return self.cached(name, opt)
+}
+
+// 每次构建时相同的assets只生成一次(出错时也只处理一次), 避免每个页面都重新生成, 不同的assets可以同时生成
+func (self *assets) cached(key string, opt option) (*result, error) {
+	self.mu.Lock()
+	c, ok := self.results[key]
+	if !ok {
+		c = &call{}
+		self.results[key] = c
+	}
+	self.mu.Unlock()
+
+	c.once.Do(func() {
+		c.res, c.err = self.execute(opt)
+	})
+	return c.res, c.err
+}
+
+// css/style.min.css -> css/style.3f2a9c1e.min.css
+func fingerprint(file string, hash string) string {
+	dir, base := path.Split(file)
+	if idx := strings.Index(base, "."); idx > 0 {
+		return dir + base[:idx] + "." + hash + base[idx:]
+	}
+	return dir + base + "." + hash
+}
+
+// 记录原始路径和带有hash的路径, 方便其它工具使用
+func (self *assets) addManifest(output, url string) {
+	if self.manifest == "" {
+		return
+	}
+	self.manifestMu.Lock()
+	defer self.manifestMu.Unlock()
+
+	self.manifestData[output] = url
+}
+
+// 所有文件写入完成后只写入一次manifest
+func (self *assets) AfterWrite(ctx *hook.Context) error {
+	if self.manifest == "" {
+		return nil
+	}
+	self.manifestMu.Lock()
+	defer self.manifestMu.Unlock()
+
+	if len(self.manifestData) == 0 {
+		return nil
+	}
+	buf, err := json.MarshalIndent(self.manifestData, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ctx.Writer.Write(self.manifest, bytes.NewReader(buf))
+}
+
 func New(conf config.Config, theme theme.Theme) hook.Hook {
 	opts := make(map[string]option)
 	meta := conf.GetStringMap("hooks.assets")
 	for name := range meta {
+		if name == "manifest" {
+			continue
+		}
 		opt := option{
-			files:   conf.GetStringSlice(fmt.Sprintf(filesTemplate, name)),
-			output:  conf.GetString(fmt.Sprintf(outputTemplate, name)),
-			version: conf.GetBool(fmt.Sprintf(versionTemplate, name)),
+			files:       conf.GetStringSlice(fmt.Sprintf(filesTemplate, name)),
+			output:      conf.GetString(fmt.Sprintf(outputTemplate, name)),
+			version:     conf.GetBool(fmt.Sprintf(versionTemplate, name)),
+			fingerprint: conf.GetBool(fmt.Sprintf(fingerprintTemplate, name)),
 		}
 		if len(opt.files) == 0 || opt.output == "" {
 			continue
@@ -65,7 +154,15 @@ func New(conf config.Config, theme theme.Theme) hook.Hook {
 		opt.filters, opt.filterOpts = filterOptions(conf.Get(fmt.Sprintf(filtersTemplate, name)))
 		opts[name] = opt
 	}
-	h := &assets{conf: conf, opts: opts, hash: make(map[string]string), theme: theme}
+	h := &assets{
+		conf:         conf,
+		opts:         opts,
+		theme:        theme,
+		results:      make(map[string]*call),
+		filters:      make(map[string]Filter),
+		manifest:     conf.GetString("hooks.assets.manifest"),
+		manifestData: make(map[string]string),
+	}
 	template.RegisterTag("assets", h.assetParser)
 	return h
 }
@@ -0,0 +1,120 @@
+package assets
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/honmaple/snow/builder/hook"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type testWriter struct {
+	files   map[string]string
+	watched []string
+}
+
+func (w *testWriter) Write(file string, r io.Reader) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	w.files[file] = string(buf)
+	return nil
+}
+
+func (w *testWriter) Watch(file string) error {
+	w.watched = append(w.watched, file)
+	return nil
+}
+
+func newTestAssets(t *testing.T) (*assets, string) {
+	dir := t.TempDir()
+	conf := config.DefaultConfig()
+	conf.OutputDir = filepath.Join(dir, "output")
+
+	th, err := theme.New(conf)
+	assert.Nil(t, err)
+	return &assets{
+		conf:         conf,
+		theme:        th,
+		results:      make(map[string]*call),
+		filters:      make(map[string]Filter),
+		manifest:     "assets.json",
+		manifestData: make(map[string]string),
+	}, dir
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		file   string
+		expect string
+	}{
+		{"css/style.min.css", "css/style.3f2a9c1e.min.css"},
+		{"js/main.js", "js/main.3f2a9c1e.js"},
+		{"main", "main.3f2a9c1e"},
+		{"css/.hidden", "css/.hidden.3f2a9c1e"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expect, fingerprint(test.file, "3f2a9c1e"))
+	}
+}
+
+func TestExecute(t *testing.T) {
+	h, dir := newTestAssets(t)
+
+	content := "body { color: red; }\n"
+	file := filepath.Join(dir, "style.css")
+	assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+
+	sri := sha512.Sum384([]byte(content))
+	integrity := "sha384-" + base64.StdEncoding.EncodeToString(sri[:])
+
+	tests := []struct {
+		opt    option
+		url    string
+		output string
+	}{
+		{option{files: []string{file}, output: "css/a.css"}, "css/a.css", "css/a.css"},
+		{option{files: []string{file}, output: "css/b.css", version: true}, "css/b.css?", "css/b.css"},
+		{option{files: []string{file}, output: "css/c.min.css", fingerprint: true}, "css/c.", ""},
+		{option{files: []string{file}, output: "css/d.css", fingerprint: true, version: true}, "css/d.", ""},
+	}
+	for _, test := range tests {
+		res, err := h.execute(test.opt)
+		assert.Nil(t, err)
+		assert.Equal(t, integrity, res.integrity)
+		assert.True(t, strings.HasPrefix(res.url, test.url), res.url)
+		if test.opt.fingerprint {
+			// 文件名中已经包含hash时不再添加版本号
+			assert.Equal(t, fingerprint(test.opt.output, res.hash[:8]), res.url)
+		} else if test.opt.version {
+			assert.Equal(t, test.output+"?"+res.hash[:8], res.url)
+		}
+
+		output := strings.SplitN(res.url, "?", 2)[0]
+		buf, err := os.ReadFile(filepath.Join(h.conf.OutputDir, output))
+		assert.Nil(t, err)
+		assert.Equal(t, content, string(buf))
+	}
+
+	// 写入完成后只写入一次manifest
+	w := &testWriter{files: make(map[string]string)}
+	assert.Nil(t, h.AfterWrite(&hook.Context{Writer: w}))
+	assert.Equal(t, 1, len(w.files))
+
+	manifest := make(map[string]string)
+	assert.Nil(t, json.NewDecoder(bytes.NewBufferString(w.files["assets.json"])).Decode(&manifest))
+	assert.Equal(t, 4, len(manifest))
+	assert.Equal(t, "css/a.css", manifest["css/a.css"])
+	assert.NotEqual(t, "css/c.min.css", manifest["css/c.min.css"])
+}
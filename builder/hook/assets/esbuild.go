@@ -0,0 +1,147 @@
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/spf13/cast"
+)
+
+var (
+	esbuildTargets = map[string]api.Target{
+		"esnext": api.ESNext,
+		"es5":    api.ES5,
+		"es2015": api.ES2015,
+		"es2016": api.ES2016,
+		"es2017": api.ES2017,
+		"es2018": api.ES2018,
+		"es2019": api.ES2019,
+		"es2020": api.ES2020,
+		"es2021": api.ES2021,
+		"es2022": api.ES2022,
+	}
+	esbuildFormats = map[string]api.Format{
+		"iife": api.FormatIIFE,
+		"esm":  api.FormatESModule,
+		"cjs":  api.FormatCommonJS,
+	}
+	esbuildLoaders = map[string]api.Loader{
+		".js":   api.LoaderJS,
+		".mjs":  api.LoaderJS,
+		".jsx":  api.LoaderJSX,
+		".ts":   api.LoaderTS,
+		".mts":  api.LoaderTS,
+		".tsx":  api.LoaderTSX,
+		".css":  api.LoaderCSS,
+		".json": api.LoaderJSON,
+	}
+)
+
+//   - esbuild:
+//     target: "es2017"
+//     format: "iife"
+//     minify: true
+//     sourcemap: true
+//     path: ["@theme/static/js/"]
+func esbuild(conf config.Config, theme theme.Theme) Filter {
+	return func(w io.Writer, r io.Reader, file string, opt FilterOption) error {
+		bs, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		loader, ok := esbuildLoaders[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			loader = api.LoaderJS
+		}
+		options := api.BuildOptions{
+			Stdin: &api.StdinOptions{
+				Contents:   string(bs),
+				Sourcefile: filepath.Base(file),
+				Loader:     loader,
+			},
+			Bundle:   true,
+			Target:   api.ES2017,
+			Format:   api.FormatIIFE,
+			Metafile: true,
+			LogLevel: api.LogLevelSilent,
+		}
+		// 内置主题文件不能解析相对路径的import
+		if !strings.HasPrefix(file, "@theme/") {
+			options.Stdin.ResolveDir = filepath.Dir(file)
+		}
+		if opt != nil {
+			if v, ok := opt["bundle"]; ok {
+				options.Bundle = cast.ToBool(v)
+			}
+			if v, ok := opt["target"]; ok {
+				target, ok := esbuildTargets[strings.ToLower(cast.ToString(v))]
+				if !ok {
+					return errors.New("esbuild: unknown target " + cast.ToString(v))
+				}
+				options.Target = target
+			}
+			if v, ok := opt["format"]; ok {
+				format, ok := esbuildFormats[strings.ToLower(cast.ToString(v))]
+				if !ok {
+					return errors.New("esbuild: unknown format " + cast.ToString(v))
+				}
+				options.Format = format
+			}
+			if cast.ToBool(opt["minify"]) {
+				options.MinifyWhitespace = true
+				options.MinifyIdentifiers = true
+				options.MinifySyntax = true
+			}
+			// 多个文件会合并成一个输出, 所以只支持inline
+			if cast.ToBool(opt["sourcemap"]) {
+				options.Sourcemap = api.SourceMapInline
+			}
+			for _, path := range cast.ToStringSlice(opt["path"]) {
+				options.NodePaths = append(options.NodePaths, theme.Path(path))
+			}
+			options.External = cast.ToStringSlice(opt["external"])
+			options.Define = cast.ToStringMapString(opt["define"])
+		}
+
+		result := api.Build(options)
+		if len(result.Errors) > 0 {
+			msgs := api.FormatMessages(result.Errors, api.FormatMessagesOptions{Kind: api.ErrorMessage})
+			return errors.New(strings.TrimSpace(strings.Join(msgs, "\n")))
+		}
+		watchMetafile(conf, result.Metafile)
+
+		for _, out := range result.OutputFiles {
+			if strings.HasSuffix(out.Path, ".map") {
+				continue
+			}
+			if _, err := w.Write(out.Contents); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// server模式下import的文件修改后也需要重新生成
+func watchMetafile(conf config.Config, metafile string) {
+	var meta struct {
+		Inputs map[string]interface{} `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return
+	}
+	for input := range meta.Inputs {
+		if strings.HasPrefix(input, "<") || strings.Contains(input, "node_modules/") {
+			continue
+		}
+		conf.Watch(input)
+	}
+}
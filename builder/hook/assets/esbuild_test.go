@@ -0,0 +1,65 @@
+package assets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEsbuild(t *testing.T) {
+	h, dir := newTestAssets(t)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "lib.ts"), []byte("export const hello = (name: string): string => `hello ${name}`\n"), 0644))
+
+	filter := esbuild(h.conf, h.theme)
+	file := filepath.Join(dir, "main.ts")
+	src := "import { hello } from './lib'\nconsole.log(hello('snow'))\n"
+
+	tests := []struct {
+		opt      FilterOption
+		contains []string
+		err      string
+	}{
+		// 默认打包为iife, 相对路径的import会合并到输出中
+		{opt: nil, contains: []string{"(() => {", "hello ${name}", "console.log"}},
+		{opt: FilterOption{"format": "esm", "minify": true}, contains: []string{"console.log"}},
+		{opt: FilterOption{"sourcemap": true}, contains: []string{"sourceMappingURL=data:"}},
+		{opt: FilterOption{"define": map[string]interface{}{"DEBUG": "false"}}, contains: []string{"console.log"}},
+		{opt: FilterOption{"target": "es3"}, err: "esbuild: unknown target es3"},
+		{opt: FilterOption{"format": "amd"}, err: "esbuild: unknown format amd"},
+	}
+	for _, test := range tests {
+		var w bytes.Buffer
+		err := filter(&w, strings.NewReader(src), file, test.opt)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err)
+			continue
+		}
+		assert.Nil(t, err)
+		for _, s := range test.contains {
+			assert.Contains(t, w.String(), s)
+		}
+		assert.NotContains(t, w.String(), "import {")
+	}
+
+	// 语法错误时返回esbuild的错误信息
+	var w bytes.Buffer
+	err := filter(&w, strings.NewReader("const = 1"), file, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "main.ts")
+
+	// server模式下import的文件修改后也需要重新生成
+	tw := &testWriter{files: make(map[string]string)}
+	conf := h.conf.WithWriter(tw)
+	assert.Nil(t, esbuild(conf, h.theme)(&w, strings.NewReader(src), file, nil))
+	watched := make([]string, 0)
+	for _, file := range tw.watched {
+		watched = append(watched, filepath.Base(file))
+	}
+	sort.Strings(watched)
+	assert.Equal(t, []string{"lib.ts", "main.ts"}, watched)
+}
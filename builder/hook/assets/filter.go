@@ -3,23 +3,40 @@ package assets
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/bep/golibsass/libsass"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
 	"github.com/spf13/cast"
 	"github.com/tdewolff/minify/v2"
 	"github.com/tdewolff/minify/v2/css"
 	"github.com/tdewolff/minify/v2/js"
 )
 
-type filterOption map[string]interface{}
+type (
+	FilterOption  map[string]interface{}
+	Filter        func(w io.Writer, r io.Reader, file string, opt FilterOption) error
+	FilterCreator func(config.Config, theme.Theme) Filter
+)
+
+var (
+	_filters   = make(map[string]FilterCreator)
+	_filtersMu sync.RWMutex
+)
 
-func filterOptions(data interface{}) (names []string, opts []filterOption) {
+func filterOptions(data interface{}) (names []string, opts []FilterOption) {
 	if data == nil {
 		return
 	}
@@ -39,18 +56,16 @@ func filterOptions(data interface{}) (names []string, opts []filterOption) {
 	case reflect.String:
 		// libcass,css
 		names = strings.Split(data.(string), ",")
-		opts = make([]filterOption, len(names))
+		opts = make([]FilterOption, len(names))
 		return
 	default:
 		return
 	}
 }
 
-func (self *assets) execute(opt option) (string, error) {
-	var (
-		b bytes.Buffer
-		h = md5.New()
-	)
+func (self *assets) execute(opt option) (*result, error) {
+	var b bytes.Buffer
+
 	for _, file := range opt.files {
 		var matches []string
 
@@ -59,7 +74,7 @@ func (self *assets) execute(opt option) (string, error) {
 		} else {
 			files, err := filepath.Glob(self.theme.Path(file))
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			matches = files
 		}
@@ -73,92 +88,181 @@ func (self *assets) execute(opt option) (string, error) {
 			if strings.HasPrefix(match, "@theme/") {
 				f, err := self.theme.Open(match)
 				if err != nil {
-					return "", err
+					return nil, err
 				}
 				buf, err = ioutil.ReadAll(f)
 			} else {
+				self.conf.Watch(match)
 				buf, err = ioutil.ReadFile(match)
 			}
 			if err != nil {
-				return "", err
+				return nil, err
 			}
-			var (
-				w = bytes.NewBuffer(nil)
-				r = bytes.NewBuffer(buf)
-			)
-			// filters为空时返回原数据
-			w.Write(r.Bytes())
-			for i, filter := range opt.filters {
-				w.Reset()
-				if err := self.filter(filter, w, r, opt.filterOpts[i]); err != nil {
-					return "", err
-				}
-				r.Reset()
-				r.Write(w.Bytes())
+			content, err := self.process(opt, match, buf)
+			if err != nil {
+				return nil, err
 			}
-			b.Write(w.Bytes())
+			b.Write(content)
 		}
 
 	}
-	// 边读边写
-	if err := self.conf.Write(opt.output, io.TeeReader(&b, h)); err != nil {
-		return "", err
+	content := b.Bytes()
+
+	sum := md5.Sum(content)
+	hash := hex.EncodeToString(sum[:])
+
+	output := opt.output
+	if opt.fingerprint {
+		output = fingerprint(output, hash[:8])
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
-}
+	if err := self.conf.Write(output, bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	self.addManifest(opt.output, output)
 
-func (self *assets) filter(name string, w io.Writer, r io.Reader, opt filterOption) (err error) {
-	switch name {
-	case "libscss":
-		err = self.libscss(w, r, opt)
-	case "cssmin":
-		err = self.cssmin(w, r, opt)
-	case "jsmin":
-		err = self.jsmin(w, r, opt)
+	sri := sha512.Sum384(content)
+	res := &result{
+		url:       output,
+		hash:      hash,
+		integrity: "sha384-" + base64.StdEncoding.EncodeToString(sri[:]),
+	}
+	// 文件名中已经包含hash时不再添加版本号
+	if opt.version && !opt.fingerprint {
+		res.url = output + "?" + hash[:8]
 	}
-	return err
+	return res, nil
 }
 
-func (self *assets) libscss(w io.Writer, r io.Reader, opt filterOption) error {
-	bs, err := ioutil.ReadAll(r)
-	if err != nil {
-		return err
+// 相同的文件, filters和参数只处理一次, server模式下文件修改后会清除缓存
+func (self *assets) process(opt option, file string, buf []byte) ([]byte, error) {
+	if len(opt.filters) == 0 {
+		return buf, nil
+	}
+	key := ""
+	if args, err := json.Marshal([]interface{}{opt.filters, opt.filterOpts, file}); err == nil {
+		h := sha256.New()
+		h.Write(args)
+		h.Write(buf)
+		key = "assets:" + hex.EncodeToString(h.Sum(nil))
+		if v, ok := self.conf.Cache.Load(key); ok {
+			return v.([]byte), nil
+		}
 	}
 
-	opts := libsass.Options{}
-	if opt != nil {
-		paths := make([]string, 0)
-		for _, path := range cast.ToStringSlice(opt["path"]) {
-			paths = append(paths, self.theme.Path(path))
+	var (
+		w = bytes.NewBuffer(nil)
+		r = bytes.NewBuffer(buf)
+	)
+	for i, name := range opt.filters {
+		filter, err := self.filter(name)
+		if err != nil {
+			return nil, err
+		}
+		w.Reset()
+		if err := filter(w, r, file, opt.filterOpts[i]); err != nil {
+			return nil, err
 		}
-		opts.IncludePaths = paths
+		r.Reset()
+		r.Write(w.Bytes())
 	}
+	if key != "" {
+		self.conf.Cache.Store(key, w.Bytes())
+	}
+	return w.Bytes(), nil
+}
 
-	transpiler, err := libsass.New(opts)
-	if err != nil {
-		return err
+// 第一次使用时才创建filter, sofile等插件可能在assets之后注册
+func (self *assets) filter(name string) (Filter, error) {
+	self.filtersMu.Lock()
+	defer self.filtersMu.Unlock()
+
+	if f, ok := self.filters[name]; ok {
+		return f, nil
+	}
+	_filtersMu.RLock()
+	c, ok := _filters[name]
+	_filtersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("filter %s not found", name)
 	}
+	f := c(self.conf, self.theme)
+	self.filters[name] = f
+	return f, nil
+}
+
+func libscss(conf config.Config, theme theme.Theme) Filter {
+	return func(w io.Writer, r io.Reader, file string, opt FilterOption) error {
+		bs, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		opts := libsass.Options{}
+		if !strings.HasPrefix(file, "@theme/") {
+			opts.IncludePaths = append(opts.IncludePaths, filepath.Dir(file))
+		}
+		if opt != nil {
+			for _, path := range cast.ToStringSlice(opt["path"]) {
+				path = theme.Path(path)
+				// import的文件修改后需要重新生成
+				conf.Watch(path)
+				opts.IncludePaths = append(opts.IncludePaths, path)
+			}
+			if cast.ToBool(opt["sourcemap"]) {
+				opts.SourceMapOptions = libsass.SourceMapOptions{
+					Filename:       filepath.Base(file) + ".map",
+					InputPath:      file,
+					Contents:       true,
+					EnableEmbedded: true,
+				}
+			}
+		}
+
+		transpiler, err := libsass.New(opts)
+		if err != nil {
+			return err
+		}
 
-	result, err := transpiler.Execute(string(bs))
-	if err != nil {
+		result, err := transpiler.Execute(string(bs))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, result.CSS)
 		return err
 	}
-	_, err = io.WriteString(w, result.CSS)
-	return err
 }
 
-func (self *assets) cssmin(w io.Writer, r io.Reader, opt filterOption) error {
-	m := minify.New()
-	m.AddFunc("css", css.Minify)
+func cssmin(conf config.Config, theme theme.Theme) Filter {
+	return func(w io.Writer, r io.Reader, file string, opt FilterOption) error {
+		m := minify.New()
+		m.AddFunc("css", css.Minify)
 
-	return m.Minify("css", w, r)
+		return m.Minify("css", w, r)
+	}
 }
 
-func (self *assets) jsmin(w io.Writer, r io.Reader, opt filterOption) error {
-	m := minify.New()
-	m.AddFunc("js", js.Minify)
+func jsmin(conf config.Config, theme theme.Theme) Filter {
+	return func(w io.Writer, r io.Reader, file string, opt FilterOption) error {
+		m := minify.New()
+		m.AddFunc("js", js.Minify)
+
+		// 多个js文件合并如果没有;会有问题
+		defer w.Write([]byte(";"))
+		return m.Minify("js", w, r)
+	}
+}
+
+func RegisterFilter(name string, c FilterCreator) {
+	_filtersMu.Lock()
+	defer _filtersMu.Unlock()
+
+	_filters[name] = c
+}
 
-	// 多个js文件合并如果没有;会有问题
-	defer w.Write([]byte(";"))
-	return m.Minify("js", w, r)
+func init() {
+	RegisterFilter("libscss", libscss)
+	RegisterFilter("libsass", libscss)
+	RegisterFilter("cssmin", cssmin)
+	RegisterFilter("jsmin", jsmin)
+	RegisterFilter("esbuild", esbuild)
 }
@@ -0,0 +1,110 @@
+package assets
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterOptions(t *testing.T) {
+	tests := []struct {
+		data  interface{}
+		names []string
+		opts  []FilterOption
+	}{
+		{nil, nil, nil},
+		{"libsass,cssmin", []string{"libsass", "cssmin"}, []FilterOption{nil, nil}},
+		{
+			[]interface{}{
+				map[string]interface{}{"libsass": map[string]interface{}{"path": "@theme/static"}},
+				map[string]interface{}{"cssmin": nil},
+			},
+			[]string{"libsass", "cssmin"},
+			[]FilterOption{{"path": "@theme/static"}, {}},
+		},
+		{1, nil, nil},
+	}
+	for _, test := range tests {
+		names, opts := filterOptions(test.data)
+		assert.Equal(t, test.names, names)
+		assert.Equal(t, test.opts, opts)
+	}
+}
+
+func TestFilterCache(t *testing.T) {
+	var created, called int32
+	RegisterFilter("test_upper", func(config.Config, theme.Theme) Filter {
+		atomic.AddInt32(&created, 1)
+		return func(w io.Writer, r io.Reader, file string, opt FilterOption) error {
+			atomic.AddInt32(&called, 1)
+			buf, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, strings.ToUpper(string(buf)))
+			return err
+		}
+	})
+	defer func() {
+		_filtersMu.Lock()
+		delete(_filters, "test_upper")
+		_filtersMu.Unlock()
+	}()
+
+	h, dir := newTestAssets(t)
+	file := filepath.Join(dir, "a.js")
+	assert.Nil(t, os.WriteFile(file, []byte("var a = 1"), 0644))
+
+	h.opts = map[string]option{
+		"js": {files: []string{file}, output: "js/a.js", filters: []string{"test_upper", "test_upper"}, filterOpts: []FilterOption{nil, nil}},
+	}
+
+	// 同时使用时只生成一次
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := h.named("js")
+			assert.Nil(t, err)
+			assert.Equal(t, "js/a.js", res.url)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), created)
+	assert.Equal(t, int32(2), called)
+
+	buf, err := os.ReadFile(filepath.Join(h.conf.OutputDir, "js/a.js"))
+	assert.Nil(t, err)
+	assert.Equal(t, "VAR A = 1", string(buf))
+
+	// 下一次构建时相同的文件和filters使用缓存的结果
+	h.results = make(map[string]*call)
+	_, err = h.named("js")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), called)
+
+	// 文件修改后重新处理
+	assert.Nil(t, os.WriteFile(file, []byte("var b = 2"), 0644))
+	h.results = make(map[string]*call)
+	_, err = h.named("js")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), called)
+
+	// 没有配置的assets直接使用output
+	res, err := h.named("css")
+	assert.Nil(t, err)
+	assert.Equal(t, "", res.url)
+
+	_, err = h.execute(option{files: []string{file}, output: "js/b.js", filters: []string{"unknown"}, filterOpts: []FilterOption{nil}})
+	assert.EqualError(t, err, "filter unknown not found")
+}
@@ -15,9 +15,10 @@ type assetNode struct {
 }
 
 func (node *assetNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
-	hash := ""
-	assetURL := ""
-	showVersion := false
+	var (
+		res *result
+		err error
+	)
 	if node.name == "" {
 		opt := option{}
 		for key, value := range node.pairs {
@@ -34,26 +35,21 @@ func (node *assetNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.Templ
 				opt.output = val.String()
 			case "version":
 				opt.version = val.Bool()
+			case "fingerprint":
+				opt.fingerprint = val.Bool()
 			}
 		}
-		opt.filterOpts = make([]filterOption, len(opt.filters))
-		h, err := node.assets.execute(opt)
-		if err != nil {
-			return &pongo2.Error{Sender: "tag:assets", OrigError: err}
-		}
-		hash = h
-		assetURL = opt.output
-		showVersion = opt.version
+		opt.filterOpts = make([]FilterOption, len(opt.filters))
+		res, err = node.assets.cached(fmt.Sprintf("@inline:%v", opt), opt)
 	} else {
-		hash = node.assets.hash[node.name]
-		assetURL = node.assets.conf.GetString(fmt.Sprintf(outputTemplate, node.name))
-		showVersion = node.assets.conf.GetBool(fmt.Sprintf(versionTemplate, node.name))
+		res, err = node.assets.named(node.name)
 	}
-	if hash != "" && showVersion {
-		assetURL = assetURL + "?" + hash[:8]
+	if err != nil {
+		return &pongo2.Error{Sender: "tag:assets", OrigError: err}
 	}
 	newctx := pongo2.NewChildExecutionContext(ctx)
-	newctx.Private["asset_url"] = assetURL
+	newctx.Private["asset_url"] = res.url
+	newctx.Private["asset_integrity"] = res.integrity
 	return node.wrapper.Execute(newctx, writer)
 }
 
@@ -4,40 +4,130 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"strings"
-
-	// "crypto/sha256"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/flosch/pongo2/v6"
-	// "golang.org/x/crypto/pbkdf2"
-
-	"crypto/md5"
-
 	"github.com/honmaple/snow/builder/hook"
 	"github.com/honmaple/snow/builder/page"
 	"github.com/honmaple/snow/builder/theme"
 	"github.com/honmaple/snow/builder/theme/template"
 	"github.com/honmaple/snow/config"
+	"golang.org/x/crypto/pbkdf2"
 )
 
-type Encrypt struct {
-	hook.BaseHook
-	conf config.Config
-	iv   []byte
+const (
+	version    = "v2"
+	saltSize   = 16
+	iterations = 100000
+
+	defaultDescription = "这是一篇加密的文章，你需要输入正确的密码."
+)
+
+type (
+	Key struct {
+		Name        string
+		Password    string
+		Description string
+	}
+	Encrypt struct {
+		hook.BaseHook
+		conf       config.Config
+		legacy     bool
+		iterations int
+		mu         sync.Mutex
+		keys       map[string]*Key
+		// taxonomy -> term -> key
+		taxonomies map[string]map[string]string
+		kinds      []string
+	}
+)
+
+func (e *Encrypt) deriveKey(password string, salt []byte, iter int) []byte {
+	return pbkdf2.Key([]byte(password), salt, iter, 32, sha256.New)
 }
 
-func (e *Encrypt) deriveKey(key string) []byte {
-	// return pbkdf2.Key([]byte(key), e.salt, 1000, 32, sha256.New)
+// v2$<iterations>$<salt>$<nonce>$<ciphertext>, 每次加密使用随机的salt和nonce
+func (e *Encrypt) encrypt(plaintext, password string) (string, error) {
+	if e.legacy {
+		return e.encryptLegacy(plaintext, password)
+	}
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(e.deriveKey(password, salt, e.iterations))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	encrypted := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	return strings.Join([]string{
+		version,
+		strconv.Itoa(e.iterations),
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(nonce),
+		base64.StdEncoding.EncodeToString(encrypted),
+	}, "$"), nil
+}
+
+func (e *Encrypt) decrypt(ciphertext, password string) (string, error) {
+	parts := strings.Split(ciphertext, "$")
+	if len(parts) != 5 || parts[0] != version {
+		return e.decryptLegacy(ciphertext, password)
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", err
+	}
+	data := make([][]byte, 3)
+	for i, part := range parts[2:] {
+		b, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return "", err
+		}
+		data[i] = b
+	}
+	block, err := aes.NewCipher(e.deriveKey(password, data[0], iter))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data[1]) != gcm.NonceSize() {
+		return "", errors.New("invalid nonce size")
+	}
+	decrypted, err := gcm.Open(nil, data[1], data[2], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// 旧的加密方式(md5+AES-CBC), 只用于兼容已经发布的内容
+func (e *Encrypt) deriveLegacyKey(key string) []byte {
 	has := md5.Sum([]byte(key))
 	return []byte(fmt.Sprintf("%x", has))
-
-	// h := md5.New()
-	// h.Write([]byte(key))
-	// return h.Sum(nil)
-	// return hex.En(h.Sum(nil))
 }
 
 func (e *Encrypt) pkcs7Padding(data []byte, blockSize int) []byte {
@@ -46,25 +136,24 @@ func (e *Encrypt) pkcs7Padding(data []byte, blockSize int) []byte {
 	return append(data, padText...)
 }
 
-func (e *Encrypt) pkcs7UnPadding(data []byte) []byte {
+func (e *Encrypt) pkcs7UnPadding(data []byte) ([]byte, error) {
 	length := len(data)
-	return data[:(length - int(data[length-1]))]
+	if length == 0 || int(data[length-1]) > length {
+		return nil, errors.New("invalid padding")
+	}
+	return data[:(length - int(data[length-1]))], nil
 }
 
-func (e *Encrypt) encrypt(plaintext, key string) (string, error) {
+func (e *Encrypt) encryptLegacy(plaintext, key string) (string, error) {
 	data := []byte(plaintext)
-	ekey := e.deriveKey(key)
+	ekey := e.deriveLegacyKey(key)
 
 	block, err := aes.NewCipher(ekey)
 	if err != nil {
 		return "", err
 	}
 	blockSize := block.BlockSize()
-	iv := e.iv
-	if len(iv) != blockSize {
-		iv = ekey[:blockSize]
-	}
-	blockMode := cipher.NewCBCEncrypter(block, iv)
+	blockMode := cipher.NewCBCEncrypter(block, ekey[:blockSize])
 
 	encryptBytes := e.pkcs7Padding(data, blockSize)
 	encrypted := make([]byte, len(encryptBytes))
@@ -72,43 +161,125 @@ func (e *Encrypt) encrypt(plaintext, key string) (string, error) {
 	return base64.StdEncoding.EncodeToString(encrypted), nil
 }
 
-func (e *Encrypt) decrypt(ciphertext, key string) (string, error) {
+func (e *Encrypt) decryptLegacy(ciphertext, key string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(ciphertext)
 	if err != nil {
 		return "", err
 	}
-	dkey := e.deriveKey(key)
+	dkey := e.deriveLegacyKey(key)
 
 	block, err := aes.NewCipher(dkey)
 	if err != nil {
 		return "", err
 	}
 	blockSize := block.BlockSize()
-	iv := e.iv
-	if len(iv) != blockSize {
-		iv = dkey[:blockSize]
+	if len(data)%blockSize != 0 {
+		return "", errors.New("invalid ciphertext size")
 	}
-	blockMode := cipher.NewCBCDecrypter(block, iv)
+	blockMode := cipher.NewCBCDecrypter(block, dkey[:blockSize])
 
 	decrypted := make([]byte, len(data))
 	blockMode.CryptBlocks(decrypted, data)
-	decrypted = e.pkcs7UnPadding(decrypted)
+	decrypted, err = e.pkcs7UnPadding(decrypted)
+	if err != nil {
+		return "", err
+	}
 	return string(decrypted), nil
 }
 
+// 从环境变量或者文件中读取密码, 避免密码出现在仓库中
+func (e *Encrypt) loadKey(name string) (*Key, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if key, ok := e.keys[name]; ok {
+		return key, nil
+	}
+	prefix := "hooks.encrypt.keys." + name
+	if !e.conf.IsSet(prefix) {
+		return nil, fmt.Errorf("key %s not found", name)
+	}
+	key := &Key{
+		Name:        name,
+		Description: e.conf.GetString(prefix + ".description"),
+	}
+	if env := e.conf.GetString(prefix + ".env"); env != "" {
+		key.Password = os.Getenv(env)
+	}
+	if file := e.conf.GetString(prefix + ".file"); file != "" && key.Password == "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key.Password = strings.TrimSpace(string(b))
+	}
+	if key.Password == "" {
+		return nil, fmt.Errorf("key %s is empty", name)
+	}
+	if key.Description == "" {
+		key.Description = defaultDescription
+	}
+	e.keys[name] = key
+	return key, nil
+}
+
+// 优先级: 页面password > 页面或者section的encrypt_key > 分类
+func (e *Encrypt) findKey(page *page.Page) (*Key, error) {
+	if password := page.Meta.GetString("password"); password != "" {
+		key := &Key{Password: password, Description: defaultDescription}
+		if v := strings.SplitN(password, ",", 2); len(v) == 2 {
+			key.Password = v[0]
+			key.Description = v[1]
+		}
+		return key, nil
+	}
+	if name := page.Meta.GetString("encrypt_key"); name != "" {
+		return e.loadKey(name)
+	}
+	// 按照分类名称的顺序查找, 匹配多个分类时结果保持一致
+	for _, kind := range e.kinds {
+		terms := e.taxonomies[kind]
+		for _, term := range page.Meta.GetSlice(kind) {
+			if name, ok := terms[term]; ok {
+				return e.loadKey(name)
+			}
+		}
+	}
+	return nil, nil
+}
+
 func (e *Encrypt) Page(page *page.Page) *page.Page {
-	password := page.Meta.GetString("password")
-	if password == "" {
-		return page
+	result, err := e.PageE(page)
+	if err != nil {
+		e.conf.Log.Errorln(err.Error())
+		return nil
+	}
+	return result
+}
+
+// 没有密码或者加密失败时构建失败, 避免泄露原文或者丢失页面
+func (e *Encrypt) PageE(page *page.Page) (*page.Page, error) {
+	key, err := e.findKey(page)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt %s: %s", page.File, err.Error())
 	}
-	description := "这是一篇加密的文章，你需要输入正确的密码."
-	if v := strings.SplitN(password, ",", 2); len(v) == 2 {
-		password = v[0]
-		description = v[1]
+	if key == nil {
+		return page, nil
 	}
-	page.Summary = fmt.Sprintf(`<shortcode _name="encrypt" password="%s" description="%s">%s</shortcode>`, password, description, page.Summary)
-	page.Content = fmt.Sprintf(`<shortcode _name="encrypt" password="%s" description="%s">%s</shortcode>`, password, description, page.Content)
-	return page
+	// 直接加密内容, 密码不会出现在页面中
+	text, err := e.encrypt(page.Content, key.Password)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt %s: %s", page.File, err.Error())
+	}
+	// 摘要不再包含任何原文, 列表页和RSS/Atom中只显示描述
+	page.Meta["encrypted"] = true
+	page.Summary = fmt.Sprintf("<p>%s</p>", html.EscapeString(key.Description))
+	page.Content = fmt.Sprintf(`<shortcode _name="encrypt" encrypted="true" description="%s">%s</shortcode>`, html.EscapeString(key.Description), text)
+	// 默认不在列表, 分类和RSS中显示
+	if !page.Meta.GetBool("encrypt_listed") {
+		page.Meta["hidden"] = true
+	}
+	return page, nil
 }
 
 func (e *Encrypt) Name() string {
@@ -150,7 +321,19 @@ func (e *Encrypt) filter(in *pongo2.Value, param *pongo2.Value) (out *pongo2.Val
 
 func New(conf config.Config, theme theme.Theme) hook.Hook {
 	e := &Encrypt{
-		conf: conf,
+		conf:       conf,
+		legacy:     conf.GetBool("hooks.encrypt.legacy"),
+		iterations: conf.GetInt("hooks.encrypt.iterations"),
+		keys:       make(map[string]*Key),
+		taxonomies: make(map[string]map[string]string),
+	}
+	for kind := range conf.GetStringMap("hooks.encrypt.taxonomies") {
+		e.taxonomies[kind] = conf.GetStringMapString("hooks.encrypt.taxonomies." + kind)
+		e.kinds = append(e.kinds, kind)
+	}
+	sort.Strings(e.kinds)
+	if e.iterations <= 0 {
+		e.iterations = iterations
 	}
 
 	template.RegisterFilter("encrypt", e.filter)
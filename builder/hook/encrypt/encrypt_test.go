@@ -0,0 +1,87 @@
+package encrypt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageE(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key")
+	assert.Nil(t, os.WriteFile(file, []byte("\n"), 0644))
+
+	conf := config.DefaultConfig()
+	conf.Set("hooks.encrypt.keys.team.file", file)
+	conf.Set("hooks.encrypt.keys.env.env", "SNOW_TEST_ENCRYPT_KEY")
+	conf.Set("hooks.encrypt.keys.env.description", "team only")
+	e := New(conf, nil).(*Encrypt)
+
+	tests := []struct {
+		meta page.Meta
+		err  string
+	}{
+		{meta: page.Meta{"encrypt_key": "unknown"}, err: "encrypt a.md: key unknown not found"},
+		{meta: page.Meta{"encrypt_key": "team"}, err: "encrypt a.md: key team is empty"},
+		{meta: page.Meta{"encrypt_key": "env"}, err: "encrypt a.md: key env is empty"},
+	}
+	for _, test := range tests {
+		p, err := e.PageE(&page.Page{File: "a.md", Meta: test.meta, Content: "secret"})
+		assert.Nil(t, p)
+		assert.EqualError(t, err, test.err)
+	}
+
+	os.Setenv("SNOW_TEST_ENCRYPT_KEY", "123456")
+	defer os.Unsetenv("SNOW_TEST_ENCRYPT_KEY")
+	p, err := e.PageE(&page.Page{File: "a.md", Meta: page.Meta{"encrypt_key": "env"}, Content: "secret"})
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(p.Content, "secret"))
+	assert.Equal(t, "<p>team only</p>", p.Summary)
+	assert.True(t, p.Meta.GetBool("hidden"))
+
+	p, err = e.PageE(&page.Page{File: "b.md", Meta: page.Meta{}, Content: "public"})
+	assert.Nil(t, err)
+	assert.Equal(t, "public", p.Content)
+}
+
+func TestEncrypt(t *testing.T) {
+	e := &Encrypt{iterations: 1000}
+	legacy := &Encrypt{legacy: true}
+
+	for _, enc := range []*Encrypt{e, legacy} {
+		text, err := enc.encrypt("hello snow", "123456")
+		assert.Nil(t, err)
+		assert.Equal(t, !enc.legacy, strings.HasPrefix(text, "v2$1000$"))
+
+		// 新版本可以解密旧的内容
+		out, err := e.decrypt(text, "123456")
+		assert.Nil(t, err)
+		assert.Equal(t, "hello snow", out)
+
+		_, err = e.decrypt(text, "654321")
+		assert.NotNil(t, err)
+	}
+
+	// 每次加密使用随机的salt和nonce
+	a, _ := e.encrypt("hello snow", "123456")
+	b, _ := e.encrypt("hello snow", "123456")
+	assert.NotEqual(t, a, b)
+
+	tests := []string{
+		"",
+		"v2$1000$abc",
+		"v2$x$AAAA$AAAA$AAAA",
+		"v2$1000$!!!!$AAAA$AAAA",
+		"v2$1000$AAAA$AAAA$AAAA",
+		"v2$1000$AAAA$AAAAAAAAAAAAAAAA$!!!!",
+		"not base64",
+	}
+	for _, test := range tests {
+		_, err := e.decrypt(test, "123456")
+		assert.NotNil(t, err, test)
+	}
+}
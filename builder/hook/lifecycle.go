@@ -0,0 +1,80 @@
+package hook
+
+import (
+	"fmt"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/static"
+	"github.com/honmaple/snow/config"
+)
+
+type (
+	// 构建时所有语言的页面和静态文件, 写入文件时请使用Writer, server模式下会写入内存
+	Context struct {
+		Config  config.Config
+		Writer  config.Writer
+		Pages   map[string]*page.Context
+		Statics map[string]*static.Context
+	}
+
+	// 开始构建之前
+	BeforeBuildHook interface {
+		BeforeBuild(*Context) error
+	}
+	// 所有页面和静态文件读取完成, 还未写入
+	AfterReadHook interface {
+		AfterRead(*Context) error
+	}
+	// 所有页面和静态文件写入完成, 可以生成sitemap, 搜索索引等
+	AfterWriteHook interface {
+		AfterWrite(*Context) error
+	}
+	// 构建完成
+	AfterBuildHook interface {
+		AfterBuild(*Context) error
+	}
+)
+
+func (hooks Hooks) BeforeBuild(ctx *Context) error {
+	for _, hook := range hooks {
+		if h, ok := hook.(BeforeBuildHook); ok {
+			if err := h.BeforeBuild(ctx); err != nil {
+				return fmt.Errorf("hook %s: %s", hook.Name(), err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (hooks Hooks) AfterRead(ctx *Context) error {
+	for _, hook := range hooks {
+		if h, ok := hook.(AfterReadHook); ok {
+			if err := h.AfterRead(ctx); err != nil {
+				return fmt.Errorf("hook %s: %s", hook.Name(), err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (hooks Hooks) AfterWrite(ctx *Context) error {
+	for _, hook := range hooks {
+		if h, ok := hook.(AfterWriteHook); ok {
+			if err := h.AfterWrite(ctx); err != nil {
+				return fmt.Errorf("hook %s: %s", hook.Name(), err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (hooks Hooks) AfterBuild(ctx *Context) error {
+	for _, hook := range hooks {
+		if h, ok := hook.(AfterBuildHook); ok {
+			if err := h.AfterBuild(ctx); err != nil {
+				return fmt.Errorf("hook %s: %s", hook.Name(), err.Error())
+			}
+		}
+	}
+	return nil
+}
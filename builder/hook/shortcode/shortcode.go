@@ -101,7 +101,6 @@ func (self *shortcode) renderNext(page *page.Page, w *bytes.Buffer, z *html.Toke
 		}
 		w.WriteString(token.String())
 	}
-	return false
 }
 
 func (self *shortcode) render(page *page.Page, content string) string {
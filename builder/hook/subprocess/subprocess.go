@@ -0,0 +1,496 @@
+package subprocess
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/honmaple/snow/builder/hook"
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/static"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+)
+
+// 协议版本, 修改请求或者响应格式时需要增加
+const Version = 1
+
+type (
+	Page struct {
+		ID        string                 `json:"id,omitempty"`
+		File      string                 `json:"file,omitempty"`
+		Lang      string                 `json:"lang,omitempty"`
+		Section   string                 `json:"section,omitempty"`
+		Slug      string                 `json:"slug,omitempty"`
+		Path      string                 `json:"path,omitempty"`
+		Permalink string                 `json:"permalink,omitempty"`
+		Date      time.Time              `json:"date"`
+		Modified  time.Time              `json:"modified"`
+		Title     *string                `json:"title,omitempty"`
+		Summary   *string                `json:"summary,omitempty"`
+		Content   *string                `json:"content,omitempty"`
+		Meta      map[string]interface{} `json:"meta,omitempty"`
+	}
+	Section struct {
+		ID        string                 `json:"id"`
+		Lang      string                 `json:"lang,omitempty"`
+		Path      string                 `json:"path,omitempty"`
+		Permalink string                 `json:"permalink,omitempty"`
+		Title     *string                `json:"title,omitempty"`
+		Content   *string                `json:"content,omitempty"`
+		Meta      map[string]interface{} `json:"meta,omitempty"`
+	}
+	Static struct {
+		ID        string `json:"id"`
+		Path      string `json:"path,omitempty"`
+		Permalink string `json:"permalink,omitempty"`
+	}
+	Request struct {
+		Version  int        `json:"version"`
+		Event    string     `json:"event"`
+		Lang     string     `json:"lang,omitempty"`
+		Pages    []*Page    `json:"pages,omitempty"`
+		Sections []*Section `json:"sections,omitempty"`
+		Statics  []*Static  `json:"statics,omitempty"`
+	}
+	Response struct {
+		Version  int        `json:"version"`
+		Error    string     `json:"error,omitempty"`
+		Pages    []*Page    `json:"pages"`
+		Sections []*Section `json:"sections"`
+		Statics  []*Static  `json:"statics"`
+	}
+
+	command struct {
+		line    string
+		name    string
+		args    []string
+		events  []string
+		timeout time.Duration
+	}
+	subprocess struct {
+		hook.BaseHook
+		conf     config.Config
+		commands []*command
+	}
+)
+
+func (self *subprocess) Name() string {
+	return "subprocess"
+}
+
+func (self *subprocess) call(cmd *command, req *Request) (*Response, error) {
+	req.Version = Version
+
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+
+	c := exec.CommandContext(ctx, cmd.name, cmd.args...)
+	c.Stdin = bytes.NewReader(buf)
+	c.Stdout = &stdout
+	// 日志直接输出到stderr
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timeout after %s", cmd.timeout)
+		}
+		return nil, err
+	}
+
+	resp := &Response{}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %s", err.Error())
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.Version != Version {
+		return nil, fmt.Errorf("unsupported protocol version %d", resp.Version)
+	}
+	return resp, nil
+}
+
+// 值为null时删除, 和读取页面时一样把字符串列表转换为[]string
+func mergeMeta(meta page.Meta, other map[string]interface{}) {
+	for k, v := range other {
+		if v == nil {
+			delete(meta, k)
+			continue
+		}
+		if vs, ok := v.([]interface{}); ok {
+			res := make([]string, 0, len(vs))
+			for _, vv := range vs {
+				s, err := cast.ToStringE(vv)
+				if err != nil {
+					break
+				}
+				res = append(res, s)
+			}
+			if len(res) == len(vs) {
+				v = res
+			}
+		}
+		meta[k] = v
+	}
+}
+
+// 生成的页面由snow补充section的默认配置, 未匹配section时使用根目录
+func (self *subprocess) newPage(item *Page, pages page.Pages) *page.Page {
+	var sec *page.Section
+	for _, p := range pages {
+		if p.Section != nil && p.Section.RealName() == item.Section {
+			sec = p.Section
+			break
+		}
+	}
+	p := &page.Page{
+		Meta:     make(page.Meta),
+		Lang:     pages[0].Lang,
+		Date:     item.Date,
+		Modified: item.Modified,
+		Slug:     item.Slug,
+		Path:     item.Path,
+		Section:  sec,
+	}
+	mergeMeta(p.Meta, item.Meta)
+
+	if item.Title != nil {
+		p.Title = *item.Title
+	}
+	if item.Summary != nil {
+		p.Summary = *item.Summary
+	}
+	if item.Content != nil {
+		p.Content = *item.Content
+	}
+	return p
+}
+
+func (self *subprocess) pages(cmd *command, pages page.Pages) (page.Pages, error) {
+	req := &Request{
+		Event: "pages",
+		Lang:  pages[0].Lang,
+		Pages: make([]*Page, len(pages)),
+	}
+	m := make(map[string]*page.Page)
+	for i, p := range pages {
+		item := &Page{
+			ID:        p.Key(),
+			File:      p.File,
+			Lang:      p.Lang,
+			Slug:      p.Slug,
+			Path:      p.Path,
+			Permalink: p.Permalink,
+			Date:      p.Date,
+			Modified:  p.Modified,
+			Title:     &pages[i].Title,
+			Summary:   &pages[i].Summary,
+			Content:   &pages[i].Content,
+			Meta:      p.Meta,
+		}
+		if p.Section != nil {
+			item.Section = p.Section.RealName()
+		}
+		req.Pages[i] = item
+		m[p.Key()] = p
+	}
+	resp, err := self.call(cmd, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先检查, 出错时不修改任何页面
+	for _, item := range resp.Pages {
+		if item.ID == "" && item.Path == "" {
+			return nil, errors.New("path is required for generated page")
+		}
+		if _, ok := m[item.ID]; item.ID != "" && !ok {
+			return nil, fmt.Errorf("page %s not found", item.ID)
+		}
+	}
+
+	result := make(page.Pages, 0, len(resp.Pages))
+	for _, item := range resp.Pages {
+		if item.ID == "" {
+			result = append(result, self.newPage(item, pages))
+			continue
+		}
+		p := m[item.ID]
+		if item.Title != nil {
+			p.Title = *item.Title
+		}
+		if item.Summary != nil {
+			p.Summary = *item.Summary
+		}
+		if item.Content != nil {
+			p.Content = *item.Content
+		}
+		if !item.Date.IsZero() {
+			p.Date = item.Date
+		}
+		if !item.Modified.IsZero() {
+			p.Modified = item.Modified
+		}
+		mergeMeta(p.Meta, item.Meta)
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+func (self *subprocess) sections(cmd *command, sections page.Sections) (page.Sections, error) {
+	req := &Request{
+		Event:    "sections",
+		Lang:     sections[0].Lang,
+		Sections: make([]*Section, len(sections)),
+	}
+	m := make(map[string]*page.Section)
+	for i, sec := range sections {
+		name := sec.RealName()
+		req.Sections[i] = &Section{
+			ID:        name,
+			Lang:      sec.Lang,
+			Path:      sec.Path,
+			Permalink: sec.Permalink,
+			Title:     &sections[i].Title,
+			Content:   &sections[i].Content,
+			Meta:      sec.Meta,
+		}
+		m[name] = sec
+	}
+	resp, err := self.call(cmd, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range resp.Sections {
+		if _, ok := m[item.ID]; !ok {
+			return nil, fmt.Errorf("section %s not found", item.ID)
+		}
+	}
+
+	result := make(page.Sections, 0, len(resp.Sections))
+	for _, item := range resp.Sections {
+		sec := m[item.ID]
+		if item.Title != nil {
+			sec.Title = *item.Title
+		}
+		if item.Content != nil {
+			sec.Content = *item.Content
+		}
+		mergeMeta(sec.Meta, item.Meta)
+		result = append(result, sec)
+	}
+	return result, nil
+}
+
+func (self *subprocess) statics(cmd *command, statics static.Statics) (static.Statics, error) {
+	req := &Request{
+		Event:   "statics",
+		Statics: make([]*Static, len(statics)),
+	}
+	m := make(map[string]*static.Static)
+	for i, s := range statics {
+		req.Statics[i] = &Static{
+			ID:        s.Name,
+			Path:      s.Path,
+			Permalink: s.Permalink,
+		}
+		m[s.Name] = s
+	}
+	resp, err := self.call(cmd, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range resp.Statics {
+		if _, ok := m[item.ID]; !ok {
+			return nil, fmt.Errorf("static %s not found", item.ID)
+		}
+	}
+
+	result := make(static.Statics, 0, len(resp.Statics))
+	for _, item := range resp.Statics {
+		s := m[item.ID]
+		if item.Path != "" && item.Path != s.Path {
+			s.Path = item.Path
+			s.Permalink = self.conf.GetURL(s.Path)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// 读取所有页面后执行, 修改的元数据(比如tags)和生成的页面同样会用于分类
+func (self *subprocess) ReadPages(pages page.Pages) (page.Pages, error) {
+	for _, cmd := range self.commands {
+		if len(pages) == 0 {
+			return pages, nil
+		}
+		if !utils.CheckInList(cmd.events, "pages") {
+			continue
+		}
+		result, err := self.pages(cmd, pages)
+		if err != nil {
+			return nil, fmt.Errorf("subprocess %s: %s", cmd.line, err.Error())
+		}
+		pages = result
+	}
+	return pages, nil
+}
+
+func (self *subprocess) SectionsE(sections page.Sections) (page.Sections, error) {
+	for _, cmd := range self.commands {
+		if len(sections) == 0 {
+			return sections, nil
+		}
+		if !utils.CheckInList(cmd.events, "sections") {
+			continue
+		}
+		result, err := self.sections(cmd, sections)
+		if err != nil {
+			return nil, fmt.Errorf("subprocess %s: %s", cmd.line, err.Error())
+		}
+		sections = result
+	}
+	return sections, nil
+}
+
+func (self *subprocess) StaticsE(statics static.Statics) (static.Statics, error) {
+	for _, cmd := range self.commands {
+		if len(statics) == 0 {
+			return statics, nil
+		}
+		if !utils.CheckInList(cmd.events, "statics") {
+			continue
+		}
+		result, err := self.statics(cmd, statics)
+		if err != nil {
+			return nil, fmt.Errorf("subprocess %s: %s", cmd.line, err.Error())
+		}
+		statics = result
+	}
+	return statics, nil
+}
+
+// 按照shell的规则拆分命令, 支持单引号, 双引号和反斜杠转义, 不支持变量和管道等其它语法
+// python3 'my script.py' --name "a b" -> [python3, my script.py, --name, a b]
+func splitCommand(line string) ([]string, error) {
+	var (
+		args    = make([]string, 0)
+		buf     strings.Builder
+		quote   rune
+		escaped bool
+		inArg   bool
+	)
+	for _, c := range line {
+		switch {
+		case escaped:
+			buf.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				buf.WriteRune(c)
+			}
+		case c == '\\':
+			// 单引号中的反斜杠没有特殊含义
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				buf.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case unicode.IsSpace(c):
+			if inArg {
+				args = append(args, buf.String())
+				buf.Reset()
+				inArg = false
+			}
+		default:
+			buf.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %s", line)
+	}
+	if escaped {
+		return nil, fmt.Errorf("unterminated escape in %s", line)
+	}
+	if inArg {
+		args = append(args, buf.String())
+	}
+	return args, nil
+}
+
+// hooks:
+//   subprocess:
+//     commands:
+//       - command: "python3 'hooks/my tags.py'"
+//         events: ["pages", "sections", "statics"]
+//         timeout: "30s"
+//       - command: ["python3", "hooks/my tags.py"]
+func New(conf config.Config, theme theme.Theme) hook.Hook {
+	commands := make([]*command, 0)
+	for _, item := range cast.ToSlice(conf.Get("hooks.subprocess.commands")) {
+		opts := cast.ToStringMap(item)
+
+		var args []string
+		// 列表中的每一项作为一个参数, 字符串按照shell的规则拆分
+		if line, ok := opts["command"].(string); ok {
+			result, err := splitCommand(line)
+			if err != nil {
+				conf.Log.Warnf("The subprocess command: %s", err.Error())
+				continue
+			}
+			args = result
+		} else {
+			args = cast.ToStringSlice(opts["command"])
+		}
+		if len(args) == 0 {
+			conf.Log.Warnf("The subprocess command is required")
+			continue
+		}
+		events := cast.ToStringSlice(opts["events"])
+		if len(events) == 0 {
+			events = []string{"pages"}
+		}
+		timeout := cast.ToDuration(opts["timeout"])
+		if timeout <= 0 {
+			timeout = time.Minute
+		}
+		commands = append(commands, &command{
+			line:    strings.Join(args, " "),
+			name:    args[0],
+			args:    args[1:],
+			events:  events,
+			timeout: timeout,
+		})
+	}
+	return &subprocess{conf: conf, commands: commands}
+}
+
+func init() {
+	hook.Register("subprocess", New)
+}
@@ -0,0 +1,91 @@
+package subprocess
+
+import (
+	"testing"
+	"time"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPages(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Site.URL = "https://example.com"
+
+	pages := page.Pages{
+		{File: "a.md", Title: "a", Meta: page.Meta{"hidden": false, "draft": true}},
+		{File: "b.md", Title: "b", Meta: page.Meta{}},
+	}
+	self := &subprocess{conf: conf}
+
+	cmd := &command{name: "cat", timeout: time.Second}
+	result, err := self.pages(cmd, pages)
+	assert.Nil(t, err)
+	assert.Equal(t, pages, result)
+
+	// 删除b.md, 修改a.md并生成新的页面
+	cmd = &command{name: "sh", args: []string{"-c", `cat >/dev/null; echo '{"version": 1, "pages": [{"path": "gen/index.html", "title": "gen"}, {"id": "a.md", "title": "A", "meta": {"draft": null, "tags": ["x"]}}]}'`}, timeout: time.Second}
+	result, err = self.pages(cmd, pages)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "gen", result[0].Title)
+	assert.Equal(t, "gen/index.html", result[0].Path)
+	assert.Nil(t, result[0].Section)
+	assert.Equal(t, "A", result[1].Title)
+	assert.Equal(t, page.Meta{"hidden": false, "tags": []string{"x"}}, result[1].Meta)
+
+	// 版本不一致时不修改页面
+	cmd = &command{name: "sh", args: []string{"-c", `cat >/dev/null; echo '{"version": 2, "pages": []}'`}, timeout: time.Second}
+	_, err = self.pages(cmd, pages)
+	assert.NotNil(t, err)
+	assert.Equal(t, "A", pages[0].Title)
+
+	// 出错时返回错误, 不再等到构建结束
+	cmd.line, cmd.events = "version", []string{"pages"}
+	self.commands = []*command{cmd}
+	_, err = self.ReadPages(pages)
+	assert.EqualError(t, err, "subprocess version: unsupported protocol version 2")
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line   string
+		expect []string
+		err    bool
+	}{
+		{line: "python3 hooks/tags.py", expect: []string{"python3", "hooks/tags.py"}},
+		{line: "python 'my script.py'", expect: []string{"python", "my script.py"}},
+		{line: `sh -c "echo \"a b\""`, expect: []string{"sh", "-c", `echo "a b"`}},
+		{line: `node my\ hook.js ''`, expect: []string{"node", "my hook.js", ""}},
+		{line: `echo 'a\b'`, expect: []string{"echo", `a\b`}},
+		{line: "  ", expect: []string{}},
+		{line: "python 'my script.py", err: true},
+		{line: `python \`, err: true},
+	}
+	for _, test := range tests {
+		args, err := splitCommand(test.line)
+		if test.err {
+			assert.NotNil(t, err, test.line)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, test.expect, args, test.line)
+	}
+}
+
+func TestNew(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("hooks.subprocess.commands", []interface{}{
+		map[string]interface{}{"command": "python 'my script.py'"},
+		map[string]interface{}{"command": []interface{}{"python", "my script.py"}, "events": []string{"statics"}},
+	})
+	self := New(conf, nil).(*subprocess)
+	assert.Equal(t, 2, len(self.commands))
+	for _, cmd := range self.commands {
+		assert.Equal(t, "python", cmd.name)
+		assert.Equal(t, []string{"my script.py"}, cmd.args)
+	}
+	assert.Equal(t, []string{"pages"}, self.commands[0].events)
+	assert.Equal(t, []string{"statics"}, self.commands[1].events)
+}
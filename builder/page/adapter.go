@@ -0,0 +1,124 @@
+package page
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+)
+
+func readAdapterRecords(file string, key string) ([]map[string]interface{}, error) {
+	data, err := utils.ReadDataFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		for _, k := range strings.Split(key, ".") {
+			m, ok := data.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("key %s not found", key)
+			}
+			data = m[k]
+		}
+	}
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("records must be a list")
+	}
+	records := make([]map[string]interface{}, 0, len(items))
+	for i, item := range items {
+		record, err := cast.ToStringMapE(item)
+		if err != nil {
+			return nil, fmt.Errorf("record %d must be a map", i)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// 使用数据文件中的每条记录生成页面, 和普通页面一样经过insertPageMeta处理
+//
+//	sections:
+//	  products:
+//	    adapter:
+//	      file: "data/products.csv"
+//	      key: "items"
+//	      fields: {title: name, date: released, tags: categories}
+//	      content_template: "adapters/product.html"
+func (b *Builder) insertAdapterPages(section *Section) {
+	if section.isRoot() {
+		return
+	}
+	// 不从section.Meta中读取, 避免子section继承后重复生成页面
+	adapter := b.conf.GetStringMap("sections." + section.RealName() + ".adapter")
+	if len(adapter) == 0 {
+		return
+	}
+	file := cast.ToString(adapter["file"])
+	if file == "" {
+		return
+	}
+	b.conf.Watch(file)
+
+	records, err := readAdapterRecords(file, cast.ToString(adapter["key"]))
+	if err != nil {
+		b.addError(fmt.Errorf("Read adapter file %s: %s", file, err.Error()))
+		return
+	}
+
+	fields := cast.ToStringMapString(adapter["fields"])
+	tplname := cast.ToString(adapter["content_template"])
+
+	for i, record := range records {
+		meta := make(Meta)
+		for k, v := range record {
+			meta[strings.ToLower(k)] = v
+		}
+		// 映射后的字段不再保留原来的名称
+		for _, key := range fields {
+			if _, ok := fields[key]; !ok {
+				delete(meta, strings.ToLower(key))
+			}
+		}
+		for field, key := range fields {
+			if v, ok := record[key]; ok {
+				meta[strings.ToLower(field)] = v
+			}
+		}
+		// csv等格式中的分类使用逗号分隔
+		for kind := range b.conf.GetStringMap("taxonomies") {
+			if v, ok := meta[kind].(string); ok {
+				meta[kind] = utils.SplitTrim(v, ",")
+			}
+		}
+		if tplname != "" {
+			if tpl := b.theme.LookupTemplate(tplname); tpl != nil {
+				content, err := tpl.Execute(map[string]interface{}{
+					"record":       record,
+					"section":      section,
+					"current_lang": b.conf.Site.Language,
+				})
+				if err != nil {
+					b.addError(fmt.Errorf("%s: record %d: %s", file, i, err.Error()))
+					continue
+				}
+				meta["content"] = content
+			}
+		}
+		if _, ok := meta["summary"]; !ok {
+			meta["summary"] = b.conf.GetSummary(meta.GetString("content"))
+		}
+
+		// 虚拟的文件路径, 用于{filename}和页面索引
+		name := meta.GetString("slug")
+		if name == "" {
+			name = b.conf.GetSlug(meta.GetString("title"))
+		}
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", utils.FileBaseName(file), i+1)
+		}
+		b.insertPageMeta(section, filepath.Join(section.File, name), "", meta)
+	}
+}
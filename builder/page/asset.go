@@ -1,7 +1,13 @@
 package page
 
 import (
+	"io"
+	"os"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/honmaple/snow/utils"
 )
 
 func (b *Builder) insertAsset(file string) {
@@ -15,5 +21,54 @@ func (b *Builder) insertAsset(file string) {
 	})
 }
 
-func (b *Builder) writeAsset(file string) {
+// 记录页面引用的文件, 输出处理时可以读取图片大小等
+func (b *Builder) insertAssetSources(pages Pages) {
+	for _, page := range pages {
+		for _, asset := range page.Assets {
+			file := asset
+			b.conf.SetSource(page.assetPath(file), func() (io.ReadCloser, error) {
+				return os.Open(file)
+			})
+		}
+	}
+}
+
+func (b *Builder) writeAsset(file string, path string) {
+	f, err := os.Open(file)
+	if err != nil {
+		b.conf.Log.Errorln(err.Error())
+		return
+	}
+	defer f.Close()
+
+	if err := b.conf.Write(path, f); err != nil {
+		b.conf.Log.Errorln(err.Error())
+	}
+}
+
+// Resource 查找页面所在目录下的文件, 文件会复制到页面的生成目录中
+func (page *Page) Resource(src string) (string, bool) {
+	if src == "" || filepath.IsAbs(src) || strings.HasPrefix(src, "/") || strings.Contains(src, "://") {
+		return src, false
+	}
+	file := filepath.Join(filepath.Dir(page.File), src)
+	if !utils.FileExists(file) {
+		return src, false
+	}
+	if !utils.CheckInList(page.Assets, file) {
+		page.Assets = append(page.Assets, file)
+	}
+	return page.assetPath(file), true
+}
+
+// 页面引用的文件复制到页面单独的目录中, 避免同一目录下的页面引用同名文件时互相覆盖
+// /posts/a/index.html -> /posts/a/cover.png, /posts/a.html -> /posts/a/cover.png
+func (page *Page) assetPath(file string) string {
+	dir := path.Dir(page.Path)
+	if strings.HasSuffix(page.Path, "/") {
+		dir = strings.TrimSuffix(page.Path, "/")
+	} else if base := path.Base(page.Path); !strings.HasPrefix(base, "index.") {
+		dir = path.Join(dir, strings.TrimSuffix(base, path.Ext(base)))
+	}
+	return path.Join("/", dir, filepath.Base(file))
 }
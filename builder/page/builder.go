@@ -2,6 +2,7 @@ package page
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -20,14 +21,21 @@ import (
 
 type (
 	Builder struct {
-		ctx     *Context
-		conf    config.Config
-		theme   theme.Theme
-		hooks   Hooks
-		readers map[string]Reader
+		ctx        *Context
+		conf       config.Config
+		theme      theme.Theme
+		hooks      Hooks
+		readers    map[string]Reader
+		history    map[string]Commits
+		shortcodes map[string]ShortcodeFunc
+		start      time.Time
+		errMu      sync.Mutex
+		errs       []string
+		pageMu     sync.Mutex
+		pending    Pages
 	}
 	Reader interface {
-		Read(io.Reader) (Meta, error)
+		Read(string) (Meta, error)
 	}
 )
 
@@ -73,13 +81,7 @@ func (b *Builder) readFile(file string) (Meta, error) {
 	if !ok {
 		return nil, fmt.Errorf("no reader for %s", file)
 	}
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	meta, err := reader.Read(f)
+	meta, err := reader.Read(file)
 	if err != nil {
 		return nil, fmt.Errorf("Read file %s: %s", file, err.Error())
 	}
@@ -91,59 +93,85 @@ func (b *Builder) readFile(file string) (Meta, error) {
 	return meta, nil
 }
 
-func (b *Builder) Build(ctx context.Context) error {
-	rootDir := b.conf.ContentDir
-	if rootDir == "" {
-		return fmt.Errorf("The content dir of %s is null", b.conf.Site.Language)
+func (b *Builder) Context() *Context {
+	return b.ctx
+}
+
+// 读取或者写入时的错误, 结束后一起返回
+func (b *Builder) addError(err error) {
+	b.errMu.Lock()
+	b.errs = append(b.errs, err.Error())
+	b.errMu.Unlock()
+}
+
+func (b *Builder) error() error {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+
+	if len(b.errs) == 0 {
+		return nil
 	}
-	b.conf.Watch(rootDir)
+	err := errors.New(strings.Join(b.errs, "\n"))
+	b.errs = nil
+	return err
+}
 
-	now := time.Now()
-	defer func() {
-		ps := make([]string, 0)
-		ls := make([]string, 0)
-		ts := make([]string, 0)
+func (b *Builder) report() {
+	ps := make([]string, 0)
+	ls := make([]string, 0)
+	ts := make([]string, 0)
 
-		lang := ""
-		if !b.conf.IsDefaultLanguage(b.conf.Site.Language) {
-			lang = "[" + b.conf.Site.Language + "]"
-		}
-		if count := len(b.ctx.Pages()); count > 0 {
-			ps = append(ps, fmt.Sprintf("%d normal pages", count))
-		}
-		if count := len(b.ctx.HiddenPages()); count > 0 {
-			ps = append(ps, fmt.Sprintf("%d hidden pages", count))
+	lang := ""
+	if !b.conf.IsDefaultLanguage(b.conf.Site.Language) {
+		lang = "[" + b.conf.Site.Language + "]"
+	}
+	if count := len(b.ctx.Pages()); count > 0 {
+		ps = append(ps, fmt.Sprintf("%d normal pages", count))
+	}
+	if count := len(b.ctx.HiddenPages()); count > 0 {
+		ps = append(ps, fmt.Sprintf("%d hidden pages", count))
+	}
+	if count := len(b.ctx.SectionPages()); count > 0 {
+		ps = append(ps, fmt.Sprintf("%d section pages", count))
+	}
+
+	for _, section := range b.ctx.Sections() {
+		if section.isRoot() {
+			continue
 		}
-		if count := len(b.ctx.SectionPages()); count > 0 {
-			ps = append(ps, fmt.Sprintf("%d section pages", count))
+		if count := len(section.Pages) + len(section.HiddenPages) + len(section.SectionPages); count > 0 {
+			ls = append(ls, fmt.Sprintf("%d %s", count, section.RealName()))
 		}
+	}
 
-		for _, section := range b.ctx.Sections() {
-			if section.isRoot() {
-				continue
-			}
-			if count := len(section.Pages) + len(section.HiddenPages) + len(section.SectionPages); count > 0 {
-				ls = append(ls, fmt.Sprintf("%d %s", count, section.RealName()))
-			}
+	for _, taxonomy := range b.ctx.Taxonomies() {
+		if count := len(taxonomy.Terms); count > 0 {
+			ts = append(ts, fmt.Sprintf("%d %s", count, taxonomy.Name))
 		}
+	}
 
-		for _, taxonomy := range b.ctx.Taxonomies() {
-			if count := len(taxonomy.Terms); count > 0 {
-				ts = append(ts, fmt.Sprintf("%d %s", count, taxonomy.Name))
-			}
-		}
+	duration := time.Now().Sub(b.start)
+	if len(ps) > 0 {
+		b.conf.Log.Infof("Done: %sPage Processed %s in %v", lang, strings.Join(ps, ", "), duration)
+	}
+	if len(ls) > 0 {
+		b.conf.Log.Infof("Done: %sSection Processed %s in %v", lang, strings.Join(ls, ", "), duration)
+	}
+	if len(ts) > 0 {
+		b.conf.Log.Infof("Done: %sTaxonomy Processed %s in %v", lang, strings.Join(ts, ", "), duration)
+	}
+}
 
-		duration := time.Now().Sub(now)
-		if len(ps) > 0 {
-			b.conf.Log.Infof("Done: %sPage Processed %s in %v", lang, strings.Join(ps, ", "), duration)
-		}
-		if len(ls) > 0 {
-			b.conf.Log.Infof("Done: %sSection Processed %s in %v", lang, strings.Join(ls, ", "), duration)
-		}
-		if len(ts) > 0 {
-			b.conf.Log.Infof("Done: %sTaxonomy Processed %s in %v", lang, strings.Join(ts, ", "), duration)
-		}
-	}()
+// 读取所有页面, 不写入文件
+func (b *Builder) Read(ctx context.Context) error {
+	rootDir := b.conf.ContentDir
+	if rootDir == "" {
+		return fmt.Errorf("The content dir of %s is null", b.conf.Site.Language)
+	}
+	b.conf.Watch(rootDir)
+	b.loadGitHistory(rootDir)
+
+	b.start = time.Now()
 
 	var wg sync.WaitGroup
 
@@ -183,6 +211,7 @@ func (b *Builder) Build(ctx context.Context) error {
 			if section == nil {
 				return nil
 			}
+			b.insertAdapterPages(section)
 			for _, file := range section.Meta.GetSlice("ignore_files") {
 				if ignoreFiles[file] {
 					continue
@@ -210,7 +239,94 @@ func (b *Builder) Build(ctx context.Context) error {
 		return err
 	}
 	tasks.Wait()
-	return b.Write()
+
+	b.pageMu.Lock()
+	pages := b.pending
+	b.pending = nil
+	b.pageMu.Unlock()
+
+	if result, err := b.hooks.readPages(pages); err != nil {
+		b.addError(err)
+	} else {
+		pages = b.ensurePages(pages, result)
+	}
+
+	r := b.resolveLinks(pages)
+	b.insertPages(pages)
+	b.insertAssetSources(pages)
+	b.resolveEmbeds(r)
+	return b.error()
+}
+
+// 插件添加的页面和普通页面一样使用section的默认配置
+func (b *Builder) ensurePages(origin Pages, pages Pages) Pages {
+	exists := make(map[*Page]bool)
+	for _, page := range origin {
+		exists[page] = true
+	}
+	for _, page := range pages {
+		if exists[page] {
+			continue
+		}
+		if page.Section == nil {
+			page.Section = b.ctx.findSection(b.conf.ContentDir)
+		}
+		meta := page.Section.pageMeta()
+		meta.load(page.Meta)
+		page.Meta = meta
+
+		if page.Lang == "" {
+			page.Lang = b.conf.Site.Language
+		}
+		if page.Date.IsZero() {
+			page.Date = time.Now()
+		}
+		if page.Modified.IsZero() {
+			page.Modified = page.Date
+		}
+		if page.Slug == "" {
+			page.Slug = b.conf.GetSlug(page.Title)
+		}
+		if page.Path == "" {
+			page.Path = page.realPath(meta.GetString("path"))
+		}
+		page.Path = b.conf.GetRelURL(page.Path)
+		page.Permalink = b.conf.GetURL(page.Path)
+		page.Formats = b.formats(page.Meta, nil)
+		if page.File == "" && page.key == "" {
+			page.key = page.Path
+		}
+	}
+	return pages
+}
+
+// 执行插件, 插件可以修改或者过滤页面
+func (b *Builder) insertPages(pages Pages) {
+	var wg sync.WaitGroup
+
+	tasks := utils.NewTaskPool(&wg, 100, func(i interface{}) {
+		defer wg.Done()
+
+		page := i.(*Page)
+		file := page.File
+
+		page, err := b.hooks.page(page)
+		if err != nil {
+			b.addError(fmt.Errorf("%s: %s", file, err.Error()))
+			return
+		}
+		if page == nil {
+			return
+		}
+		b.ctx.insertPage(page)
+		b.insertTaxonomies(page)
+	})
+	defer tasks.Release()
+
+	for _, page := range pages {
+		tasks.Invoke(page)
+	}
+	tasks.Wait()
 }
 
 func (b *Builder) write(tpl template.Writer, path string, vars map[string]interface{}) {
@@ -248,6 +364,8 @@ func (b *Builder) write(tpl template.Writer, path string, vars map[string]interf
 }
 
 func (b *Builder) Write() error {
+	defer b.report()
+
 	var wg sync.WaitGroup
 
 	tasks := utils.NewTaskPool(&wg, 10, func(i interface{}) {
@@ -267,50 +385,113 @@ func (b *Builder) Write() error {
 	defer tasks.Release()
 
 	b.ctx.ensure()
-	for _, page := range b.hooks.Pages(b.ctx.Pages()) {
-		tasks.Invoke(page)
-	}
-	for _, page := range b.hooks.Pages(b.ctx.HiddenPages()) {
-		tasks.Invoke(page)
+	for _, pages := range []Pages{b.ctx.Pages(), b.ctx.HiddenPages(), b.ctx.SectionPages()} {
+		pages, err := b.hooks.pages(pages)
+		if err != nil {
+			b.addError(err)
+		}
+		for _, page := range pages {
+			tasks.Invoke(page)
+		}
 	}
-	for _, page := range b.hooks.Pages(b.ctx.SectionPages()) {
-		tasks.Invoke(page)
+	sections, err := b.hooks.sections(b.ctx.Sections())
+	if err != nil {
+		b.addError(err)
 	}
-	for _, section := range b.hooks.Sections(b.ctx.Sections()) {
+	for _, section := range sections {
 		if section.isRoot() || section.isEmpty() {
 			continue
 		}
 		tasks.Invoke(section)
 	}
-	for _, taxonomy := range b.hooks.Taxonomies(b.ctx.Taxonomies()) {
+	taxonomies, err := b.hooks.taxonomies(b.ctx.Taxonomies())
+	if err != nil {
+		b.addError(err)
+	}
+	for _, taxonomy := range taxonomies {
 		tasks.Invoke(taxonomy)
 
-		for _, term := range b.hooks.TaxonomyTerms(taxonomy.Terms) {
+		terms, err := b.hooks.taxonomyTerms(taxonomy.Terms)
+		if err != nil {
+			b.addError(err)
+		}
+		for _, term := range terms {
 			tasks.Invoke(term)
 		}
 	}
 	tasks.Wait()
-	return nil
+	return b.error()
 }
 
 func NewBuilder(conf config.Config, theme theme.Theme, hooks Hooks) *Builder {
 	readers := make(map[string]Reader)
 	for ext, c := range _readers {
-		readers[ext] = c(conf)
+		readers[ext] = c(conf, theme)
+	}
+	shortcodes := make(map[string]ShortcodeFunc)
+	for name, c := range _shortcodes {
+		shortcodes[name] = c(conf, theme)
 	}
 	return &Builder{
-		conf:    conf,
-		theme:   theme,
-		hooks:   hooks,
-		readers: readers,
-		ctx:     newContext(conf),
+		conf:       conf,
+		theme:      theme,
+		hooks:      hooks,
+		readers:    readers,
+		shortcodes: shortcodes,
+		ctx:        newContext(conf),
 	}
 }
 
-type creator func(config.Config) Reader
+type creator func(config.Config, theme.Theme) Reader
 
 var _readers = make(map[string]creator)
 
-func Register(ext string, c creator) {
+// Register 保持旧版本的参数, 不需要主题的Reader可以继续使用
+func Register(ext string, c func(config.Config) Reader) {
+	RegisterWithTheme(ext, func(conf config.Config, _ theme.Theme) Reader {
+		return c(conf)
+	})
+}
+
+// RegisterWithTheme 主题用于查找 _markup 下的模版
+func RegisterWithTheme(ext string, c creator) {
 	_readers[ext] = c
 }
+
+// 兼容旧版本的Reader, 只读取文件内容, 不支持include等需要文件路径的功能
+type StreamReader interface {
+	Read(io.Reader) (Meta, error)
+}
+
+type streamReader struct {
+	r StreamReader
+}
+
+func (s *streamReader) Read(file string) (Meta, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.r.Read(f)
+}
+
+func RegisterStream(ext string, c func(config.Config) StreamReader) {
+	Register(ext, func(conf config.Config) Reader {
+		return &streamReader{r: c(conf)}
+	})
+}
+
+// 主题可以使用 _markup/render-{kind}.html 自定义markup元素的渲染
+func LookupRenderHooks(theme theme.Theme, kinds ...string) map[string]template.Writer {
+	hooks := make(map[string]template.Writer)
+	if theme == nil {
+		return hooks
+	}
+	for _, kind := range kinds {
+		if tpl := theme.LookupTemplate("_markup/render-" + kind + ".html"); tpl != nil {
+			hooks[kind] = tpl
+		}
+	}
+	return hooks
+}
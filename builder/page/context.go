@@ -77,7 +77,11 @@ func (ctx *Context) insertPage(page *Page) {
 		section.Pages = append(section.Pages, page)
 		ctx.pages = append(ctx.pages, page)
 	}
-	ctx.pageMap[page.File] = page
+	ctx.pageMap[page.Key()] = page
+	// 拆分的文件使用第一个页面
+	if _, ok := ctx.pageMap[page.File]; !ok {
+		ctx.pageMap[page.File] = page
+	}
 }
 
 func (ctx *Context) insertSection(section *Section) {
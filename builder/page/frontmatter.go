@@ -0,0 +1,166 @@
+package page
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/honmaple/snow/utils"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/viper"
+)
+
+var (
+	FRONTMATTER_YAML_LINE = regexp.MustCompile(`^yaml: line (\d+): `)
+	// 单独一行的"{"或者{"title": "aaa"}, 避免匹配{{< shortcode >}}
+	FRONTMATTER_JSON = regexp.MustCompile(`^\{\s*("|\}|$)`)
+)
+
+type FrontMatterError struct {
+	Line int
+	Err  error
+}
+
+func (e *FrontMatterError) Error() string {
+	return fmt.Sprintf("front matter line %d: %s", e.Line, e.Err.Error())
+}
+
+func frontMatterDelimiter(line string) (string, string) {
+	switch strings.TrimRight(line, " \t\r") {
+	case "---":
+		return "---", "yaml"
+	case "+++":
+		return "+++", "toml"
+	}
+	if FRONTMATTER_JSON.MatchString(line) {
+		return "}", "json"
+	}
+	return "", ""
+}
+
+// 行号从1开始, start为数据所在的第一行
+func parseFrontMatter(typ string, data []byte, start int) (map[string]interface{}, error) {
+	var (
+		err    error
+		result = make(map[string]interface{})
+	)
+	switch typ {
+	case "yaml":
+		err = utils.UnmarshalYAML(data, &result)
+		if err != nil {
+			if match := FRONTMATTER_YAML_LINE.FindStringSubmatch(err.Error()); match != nil {
+				line, _ := strconv.Atoi(match[1])
+				return nil, &FrontMatterError{
+					Line: start + line - 1,
+					Err:  errors.New(strings.TrimPrefix(err.Error(), match[0])),
+				}
+			}
+		}
+	case "toml":
+		err = toml.Unmarshal(data, &result)
+		if e, ok := err.(*toml.DecodeError); ok {
+			line, _ := e.Position()
+			return nil, &FrontMatterError{Line: start + line - 1, Err: err}
+		}
+	}
+	if err != nil {
+		return nil, &FrontMatterError{Line: start, Err: err}
+	}
+	return result, nil
+}
+
+// json格式直接解析到对象结束的位置, 返回剩余内容的开始位置
+func parseJSONFrontMatter(buf []byte) (map[string]interface{}, int, error) {
+	result := make(map[string]interface{})
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	if err := dec.Decode(&result); err != nil {
+		offset := int64(-1)
+		if e, ok := err.(*json.SyntaxError); ok {
+			offset = e.Offset
+		} else if e, ok := err.(*json.UnmarshalTypeError); ok {
+			offset = e.Offset
+		}
+		if offset < 0 {
+			return nil, 0, &FrontMatterError{Line: 1, Err: errors.New("json front matter is not closed")}
+		}
+		if offset > int64(len(buf)) {
+			offset = int64(len(buf))
+		}
+		line := bytes.Count(buf[:offset], []byte("\n")) + 1
+		return nil, 0, &FrontMatterError{Line: line, Err: err}
+	}
+	// 跳过"}"之后的换行
+	end := int(dec.InputOffset())
+	if idx := bytes.IndexByte(buf[end:], '\n'); idx >= 0 && len(bytes.TrimSpace(buf[end:end+idx])) == 0 {
+		end = end + idx + 1
+	}
+	return result, end, nil
+}
+
+// ReadFrontMatter 读取文件开头yaml(---), toml(+++)或者json({})格式的元数据,
+// 没有元数据时返回的meta为nil, 同时返回剩余的内容
+func ReadFrontMatter(r io.Reader) (Meta, io.Reader, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	first := buf
+	if idx := bytes.IndexByte(buf, '\n'); idx >= 0 {
+		first = buf[:idx]
+	}
+	end, typ := frontMatterDelimiter(strings.TrimRight(string(first), "\r"))
+	if typ == "" {
+		return nil, bytes.NewReader(buf), nil
+	}
+
+	var (
+		result    map[string]interface{}
+		remaining int
+	)
+	if typ == "json" {
+		result, remaining, err = parseJSONFrontMatter(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var (
+			b      bytes.Buffer
+			closed = false
+		)
+		for i, l := range bytes.SplitAfter(buf, []byte("\n")) {
+			remaining += len(l)
+			if i == 0 {
+				continue
+			}
+			text := strings.TrimRight(string(l), "\r\n")
+			if strings.TrimRight(text, " \t") == end {
+				closed = true
+				break
+			}
+			b.WriteString(text)
+			b.WriteString("\n")
+		}
+		if !closed {
+			return nil, nil, &FrontMatterError{Line: 1, Err: fmt.Errorf("%s front matter is not closed", typ)}
+		}
+		result, err = parseFrontMatter(typ, b.Bytes(), 2)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 使用viper统一key的大小写, 不要直接使用meta反序列化数据, 否则子元素map类型也会是page.Meta
+	cf := viper.New()
+	if err := cf.MergeConfigMap(result); err != nil {
+		return nil, nil, err
+	}
+	return Meta(cf.AllSettings()), bytes.NewReader(buf[remaining:]), nil
+}
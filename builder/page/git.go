@@ -0,0 +1,152 @@
+package page
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/honmaple/snow/utils"
+)
+
+type (
+	Commit struct {
+		Hash      string
+		ShortHash string
+		Author    string
+		Email     string
+		Date      time.Time
+		Subject   string
+	}
+	Commits []*Commit
+)
+
+func (cs Commits) Last() *Commit {
+	if len(cs) == 0 {
+		return nil
+	}
+	return cs[0]
+}
+
+// Authors 按照提交顺序返回去重后的作者
+func (cs Commits) Authors() []string {
+	authors := make([]string, 0)
+	for _, c := range cs {
+		if !utils.CheckInList(authors, c.Author) {
+			authors = append(authors, c.Author)
+		}
+	}
+	return authors
+}
+
+func gitCommand(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "core.quotepath=off"}, args...)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// 绝对路径, 并且解析软链接, 和git仓库的根目录保持一致
+func realPath(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Clean(file)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// 一次读取内容目录下所有文件的提交记录, 文件路径为绝对路径, 重命名之前的提交记录也属于新的文件
+func readGitHistory(dir string) (map[string]Commits, error) {
+	root, err := gitCommand(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = realPath(strings.TrimSpace(root))
+
+	out, err := gitCommand(dir, "log", "-M", "--name-status", "--format=%x1e%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%s", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		history = make(map[string]Commits)
+		// 旧的文件名 -> 当前的文件名
+		renames = make(map[string]string)
+	)
+	current := func(file string) string {
+		if name, ok := renames[file]; ok {
+			return name
+		}
+		return file
+	}
+	// 提交记录从新到旧
+	for _, record := range strings.Split(out, "\x1e") {
+		scanner := bufio.NewScanner(strings.NewReader(record))
+		if !scanner.Scan() {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[4])
+		commit := &Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Email:     fields[3],
+			Date:      date,
+			Subject:   fields[5],
+		}
+		for scanner.Scan() {
+			// M\tfile 或者 R100\told\tnew
+			status := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+			if len(status) < 2 {
+				continue
+			}
+			file := current(status[len(status)-1])
+			if strings.HasPrefix(status[0], "R") && len(status) == 3 {
+				renames[status[1]] = file
+			}
+			file = filepath.Join(root, filepath.FromSlash(file))
+			history[file] = append(history[file], commit)
+		}
+	}
+	return history, nil
+}
+
+func (b *Builder) loadGitHistory(dir string) {
+	if !b.conf.GetBool("git_info") {
+		return
+	}
+	history, err := readGitHistory(dir)
+	if err != nil {
+		// 设置了git_info时读取失败(比如没有安装git或者不是git仓库)需要中止构建
+		b.addError(fmt.Errorf("Read git history of %s: %s", dir, err.Error()))
+		return
+	}
+	b.history = history
+}
+
+func (b *Builder) findHistory(file string) (Commits, bool) {
+	if len(b.history) == 0 {
+		return nil, false
+	}
+	history, ok := b.history[realPath(file)]
+	return history, ok
+}
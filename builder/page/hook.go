@@ -11,6 +11,30 @@ type (
 		TaxonomyTerms(TaxonomyTerms) TaxonomyTerms
 	}
 	Hooks []Hook
+
+	// 可选接口, 实现后会代替对应的方法, 返回的错误会中止构建
+	PageHookE interface {
+		PageE(*Page) (*Page, error)
+	}
+	SectionHookE interface {
+		SectionE(*Section) (*Section, error)
+	}
+	PagesHookE interface {
+		PagesE(Pages) (Pages, error)
+	}
+	SectionsHookE interface {
+		SectionsE(Sections) (Sections, error)
+	}
+	TaxonomiesHookE interface {
+		TaxonomiesE(Taxonomies) (Taxonomies, error)
+	}
+	TaxonomyTermsHookE interface {
+		TaxonomyTermsE(TaxonomyTerms) (TaxonomyTerms, error)
+	}
+	// 读取所有页面后, 在替换链接和执行Page之前调用, 可以修改, 删除或者添加页面
+	ReadPagesHook interface {
+		ReadPages(Pages) (Pages, error)
+	}
 )
 
 func (hooks Hooks) Page(page *Page) *Page {
@@ -72,3 +96,109 @@ func (hooks Hooks) TaxonomyTerms(terms TaxonomyTerms) TaxonomyTerms {
 	}
 	return terms
 }
+
+// 以下方法供构建时调用, 优先使用返回错误的接口
+func (hooks Hooks) page(page *Page) (*Page, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(PageHookE); ok {
+			page, err = h.PageE(page)
+		} else {
+			page = hook.Page(page)
+		}
+		if err != nil || page == nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func (hooks Hooks) readPages(pages Pages) (Pages, error) {
+	var err error
+	for _, hook := range hooks {
+		h, ok := hook.(ReadPagesHook)
+		if !ok {
+			continue
+		}
+		pages, err = h.ReadPages(pages)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+func (hooks Hooks) section(section *Section) (*Section, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(SectionHookE); ok {
+			section, err = h.SectionE(section)
+		} else {
+			section = hook.Section(section)
+		}
+		if err != nil || section == nil {
+			return nil, err
+		}
+	}
+	return section, nil
+}
+
+func (hooks Hooks) pages(pages Pages) (Pages, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(PagesHookE); ok {
+			pages, err = h.PagesE(pages)
+		} else {
+			pages = hook.Pages(pages)
+		}
+		if err != nil || len(pages) == 0 {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+func (hooks Hooks) sections(sections Sections) (Sections, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(SectionsHookE); ok {
+			sections, err = h.SectionsE(sections)
+		} else {
+			sections = hook.Sections(sections)
+		}
+		if err != nil || len(sections) == 0 {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
+
+func (hooks Hooks) taxonomies(taxonomies Taxonomies) (Taxonomies, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(TaxonomiesHookE); ok {
+			taxonomies, err = h.TaxonomiesE(taxonomies)
+		} else {
+			taxonomies = hook.Taxonomies(taxonomies)
+		}
+		if err != nil || len(taxonomies) == 0 {
+			return nil, err
+		}
+	}
+	return taxonomies, nil
+}
+
+func (hooks Hooks) taxonomyTerms(terms TaxonomyTerms) (TaxonomyTerms, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(TaxonomyTermsHookE); ok {
+			terms, err = h.TaxonomyTermsE(terms)
+		} else {
+			terms = hook.TaxonomyTerms(terms)
+		}
+		if err != nil || len(terms) == 0 {
+			return nil, err
+		}
+	}
+	return terms, nil
+}
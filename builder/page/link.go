@@ -0,0 +1,337 @@
+package page
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/honmaple/snow/utils"
+	"golang.org/x/net/html"
+)
+
+type linkResolver struct {
+	ctx      *Context
+	ids      map[string]*Page
+	pages    map[string]*Page
+	exts     map[string]bool
+	links    func(*Page) string
+	wikis    []map[string]Pages
+	warned   map[string]bool
+	addError func(error)
+}
+
+func (r *linkResolver) warnf(page *Page, format string, args ...interface{}) {
+	msg := fmt.Sprintf("%s: %s", page.File, fmt.Sprintf(format, args...))
+	if r.warned[msg] {
+		return
+	}
+	r.warned[msg] = true
+	r.ctx.conf.Log.Warnln(msg)
+}
+
+// 设置content_link_strict后无法找到的链接作为错误
+func (r *linkResolver) errorf(page *Page, format string, args ...interface{}) {
+	if r.addError == nil {
+		r.warnf(page, format, args...)
+		return
+	}
+	msg := fmt.Sprintf("%s: %s", page.File, fmt.Sprintf(format, args...))
+	if r.warned[msg] {
+		return
+	}
+	r.warned[msg] = true
+	r.addError(errors.New(msg))
+}
+
+func (r *linkResolver) findPage(file string) *Page {
+	if strings.HasPrefix(file, "@") {
+		file = r.ctx.conf.ContentDir + file[1:]
+	}
+	return r.pages[file]
+}
+
+func (r *linkResolver) isContent(link string) bool {
+	if strings.HasPrefix(link, "id:") || strings.HasPrefix(link, "file:") || strings.HasPrefix(link, "wiki:") || strings.HasPrefix(link, "@/") {
+		return true
+	}
+	if strings.Contains(link, ":") || strings.HasPrefix(link, "/") {
+		return false
+	}
+	return r.exts[filepath.Ext(link)]
+}
+
+// 按照标题, slug, 文件名的顺序查找
+func (r *linkResolver) findWiki(page *Page, name string) *Page {
+	if n, err := url.PathUnescape(name); err == nil {
+		name = n
+	}
+	key := strings.ToLower(strings.TrimSpace(name))
+	for _, wikis := range r.wikis {
+		pages, ok := wikis[key]
+		if !ok {
+			continue
+		}
+		if len(pages) > 1 {
+			files := make([]string, len(pages))
+			for i, p := range pages {
+				files[i] = p.File
+			}
+			r.warnf(page, "ambiguous wikilink [[%s]] matches %s", name, strings.Join(files, ", "))
+		}
+		return pages[0]
+	}
+	return nil
+}
+
+func (r *linkResolver) find(page *Page, link string) (string, *Page) {
+	if strings.HasPrefix(link, "id:") {
+		target, ok := r.ids[link[3:]]
+		if !ok {
+			return "", nil
+		}
+		return r.links(target), target
+	}
+	if strings.HasPrefix(link, "wiki:") {
+		target := r.findWiki(page, link[5:])
+		if target == nil {
+			return "", nil
+		}
+		return r.links(target), target
+	}
+	link = strings.TrimPrefix(link, "file:")
+	if !strings.HasPrefix(link, "@/") {
+		link = filepath.Join(filepath.Dir(page.File), link)
+	}
+	if target := r.findPage(link); target != nil {
+		return r.links(target), target
+	}
+	// content/posts/_index.md
+	if strings.HasPrefix(filepath.Base(link), "_index.") {
+		if section := r.ctx.findSection(filepath.Dir(link)); section != nil {
+			return section.Path, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *linkResolver) addBacklink(page *Page, target *Page) {
+	if target == nil || target == page {
+		return
+	}
+	for _, p := range target.Backlinks {
+		if p == page {
+			return
+		}
+	}
+	target.Backlinks = append(target.Backlinks, page)
+}
+
+// embed为false时只替换链接, 为true时只嵌入页面
+func (r *linkResolver) resolve(page *Page, content string, embed bool) string {
+	if content == "" {
+		return content
+	}
+	var (
+		w bytes.Buffer
+		z = html.NewTokenizer(strings.NewReader(content))
+	)
+	for {
+		next := z.Next()
+		if next == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		if next != html.StartTagToken && next != html.SelfClosingTagToken {
+			w.Write(raw)
+			continue
+		}
+		token := z.Token()
+		switch token.Data {
+		case "a":
+			if embed {
+				break
+			}
+			changed := false
+			for i, attr := range token.Attr {
+				if attr.Key != "href" || attr.Val == "" || strings.HasPrefix(attr.Val, "#") {
+					continue
+				}
+				link, anchor := attr.Val, ""
+				// [[file:foo.org::#custom-id]]
+				if strings.HasPrefix(link, "file:") {
+					link = strings.Replace(link, "::#", "#", 1)
+				}
+				if idx := strings.Index(link, "#"); idx > 0 {
+					link, anchor = link[:idx], link[idx:]
+				}
+				// ref和relref shortcode分别使用绝对和相对链接
+				kind := ""
+				if strings.HasPrefix(link, "ref:") || strings.HasPrefix(link, "relref:") {
+					idx := strings.Index(link, ":")
+					kind, link = link[:idx], link[idx+1:]
+				} else if !r.isContent(link) {
+					continue
+				}
+				var (
+					newlink string
+					target  *Page
+				)
+				// 优先查找从文件中拆分的页面, foo.org#custom-id
+				if anchor != "" {
+					if newlink, target = r.find(page, link+anchor); target != nil {
+						anchor = ""
+					}
+				}
+				if target == nil {
+					newlink, target = r.find(page, link)
+				}
+				if newlink != "" {
+					switch {
+					case kind == "ref" && target != nil:
+						newlink = target.Permalink
+					case kind == "ref":
+						newlink = r.ctx.conf.GetURL(newlink)
+					case kind == "relref" && target != nil:
+						newlink = target.Path
+					}
+				}
+				if newlink == "" {
+					if strings.HasPrefix(link, "wiki:") {
+						name, _ := url.PathUnescape(link[5:])
+						r.errorf(page, "dangling wikilink [[%s]]", name)
+					} else {
+						r.errorf(page, "unresolved link %s", attr.Val)
+					}
+					continue
+				}
+				r.addBacklink(page, target)
+
+				token.Attr[i].Val = newlink + anchor
+				changed = true
+			}
+			if changed {
+				w.WriteString(token.String())
+				continue
+			}
+		case "div":
+			if !embed {
+				break
+			}
+			// ![[page]]
+			src := ""
+			for _, attr := range token.Attr {
+				if attr.Key == "data-wikilink" {
+					src = attr.Val
+					break
+				}
+			}
+			if src == "" {
+				break
+			}
+			w.Write(raw)
+			_, target := r.find(page, "wiki:"+src)
+			// 被插件过滤的页面
+			if target != nil && r.ctx.findPage(target.Key()) != target {
+				target = nil
+			}
+			if target != nil && target != page {
+				r.addBacklink(page, target)
+				w.WriteString(target.Content)
+			} else if target == nil {
+				name, _ := url.PathUnescape(src)
+				r.errorf(page, "dangling wikilink ![[%s]]", name)
+			}
+			continue
+		}
+		w.Write(raw)
+	}
+	return w.String()
+}
+
+// 在执行插件之前替换页面中指向其它内容文件的链接, 同时记录反向链接
+func (b *Builder) resolveLinks(pages Pages) *linkResolver {
+	r := &linkResolver{
+		ctx:   b.ctx,
+		ids:   make(map[string]*Page),
+		pages: make(map[string]*Page),
+		exts:  make(map[string]bool),
+		links: func(page *Page) string {
+			return page.Path
+		},
+		warned: make(map[string]bool),
+	}
+	if b.conf.GetBool("content_link_strict") {
+		r.addError = b.addError
+	}
+	if b.conf.GetBool("content_link_permalink") {
+		r.links = func(page *Page) string {
+			return page.Permalink
+		}
+	}
+	for ext := range b.readers {
+		r.exts[ext] = true
+	}
+
+	for _, page := range pages {
+		r.pages[page.Key()] = page
+		// 拆分的文件使用第一个页面
+		if _, ok := r.pages[page.File]; !ok {
+			r.pages[page.File] = page
+		}
+	}
+
+	titles := make(map[string]Pages)
+	slugs := make(map[string]Pages)
+	files := make(map[string]Pages)
+	for _, page := range pages {
+		// orgmode :ID:
+		if id := page.Meta.GetString("id"); id != "" {
+			r.ids[id] = page
+		}
+		titles[strings.ToLower(page.Title)] = append(titles[strings.ToLower(page.Title)], page)
+		slugs[strings.ToLower(page.Slug)] = append(slugs[strings.ToLower(page.Slug)], page)
+		// 从文件中拆分的页面只能使用标题或者slug
+		if page.Key() != page.File {
+			continue
+		}
+		filename := utils.FileBaseName(page.File)
+		if filename == "index" {
+			filename = filepath.Base(filepath.Dir(page.File))
+		}
+		files[strings.ToLower(filename)] = append(files[strings.ToLower(filename)], page)
+	}
+	r.wikis = []map[string]Pages{titles, slugs, files}
+
+	for _, page := range pages {
+		page.Content = r.resolve(page, page.Content, false)
+		page.Summary = r.resolve(page, page.Summary, false)
+	}
+	return r
+}
+
+// 执行插件之后再嵌入页面, 使用插件处理后的内容(比如加密)
+func (b *Builder) resolveEmbeds(r *linkResolver) {
+	pages := make(Pages, 0)
+	pages = append(pages, b.ctx.Pages()...)
+	pages = append(pages, b.ctx.HiddenPages()...)
+	pages = append(pages, b.ctx.SectionPages()...)
+
+	for _, page := range pages {
+		page.Content = r.resolve(page, page.Content, true)
+		page.Summary = r.resolve(page, page.Summary, true)
+	}
+	for _, page := range pages {
+		// 移除被插件过滤的页面
+		backlinks := page.Backlinks[:0]
+		for _, p := range page.Backlinks {
+			if b.ctx.findPage(p.Key()) == p {
+				backlinks = append(backlinks, p)
+			}
+		}
+		page.Backlinks = backlinks
+		page.Backlinks.setSort("date desc")
+	}
+}
@@ -0,0 +1,226 @@
+package asciidoc
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/builder/theme/template"
+	"github.com/honmaple/snow/config"
+)
+
+var (
+	ASCIIDOC_DOCTITLE = regexp.MustCompile(`^=\s+(.+?)\s*$`)
+	ASCIIDOC_META     = regexp.MustCompile(`^:(!?[\w-]+!?):(\s+(.*)|$)`)
+	ASCIIDOC_MORE     = regexp.MustCompile(`^//\s*(?i:more)\s*$`)
+	ASCIIDOC_AUTHOR   = regexp.MustCompile(`^([^<:]+?)\s*(?:<([^>]+)>)?$`)
+	ASCIIDOC_REVISION = regexp.MustCompile(`^v?([^,:]*?)\s*(?:,\s*([^:]+?))?\s*(?::\s*(.*))?$`)
+	ASCIIDOC_SECTION  = regexp.MustCompile(`^={2,6}\s+`)
+	ASCIIDOC_MAPPINGS = map[string]string{
+		"revdate":   "date",
+		"revnumber": "version",
+		"keywords":  "tags",
+	}
+)
+
+type asciidoc struct {
+	conf  config.Config
+	hooks map[string]template.Writer
+}
+
+func setMeta(meta page.Meta, key, value string) {
+	key = strings.ToLower(key)
+	// :name!: 表示取消属性
+	if strings.HasPrefix(key, "!") || strings.HasSuffix(key, "!") {
+		delete(meta, strings.Trim(key, "!"))
+		return
+	}
+	switch key {
+	case "tags", "keywords", "categories", "authors":
+		if !strings.HasPrefix(value, "[") {
+			value = "[" + value + "]"
+		}
+	}
+	meta.Set(key, value)
+	if k, ok := ASCIIDOC_MAPPINGS[key]; ok && k != key {
+		if _, exists := meta[k]; !exists {
+			meta.Set(k, value)
+		}
+	}
+}
+
+// 读取文档头部, 包括标题, 作者, 版本和属性
+func readMeta(r io.Reader) (page.Meta, []string, error) {
+	var (
+		meta    = make(page.Meta)
+		lines   = make([]string, 0)
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	i := 0
+	for i < len(lines) && (strings.TrimSpace(lines[i]) == "" || strings.HasPrefix(lines[i], "//") && !ASCIIDOC_MORE.MatchString(lines[i])) {
+		i++
+	}
+	header := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if match := ASCIIDOC_DOCTITLE.FindStringSubmatch(line); match != nil && header == 0 {
+			meta["title"] = match[1]
+			header++
+			continue
+		}
+		if match := ASCIIDOC_META.FindStringSubmatch(line); match != nil {
+			setMeta(meta, match[1], strings.TrimSpace(match[3]))
+			continue
+		}
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+		// 标题后的第一行为作者, 第二行为版本
+		if header == 1 {
+			if match := ASCIIDOC_AUTHOR.FindStringSubmatch(line); match != nil {
+				setMeta(meta, "author", match[1])
+				if match[2] != "" {
+					setMeta(meta, "email", match[2])
+				}
+			}
+			header++
+			continue
+		}
+		if header == 2 {
+			if match := ASCIIDOC_REVISION.FindStringSubmatch(line); match != nil {
+				if match[1] != "" {
+					setMeta(meta, "revnumber", match[1])
+				}
+				if match[2] != "" {
+					setMeta(meta, "revdate", match[2])
+				}
+				if match[3] != "" {
+					setMeta(meta, "revremark", match[3])
+				}
+			}
+			header++
+			continue
+		}
+		break
+	}
+	if len(meta) == 0 {
+		i = 0
+	}
+	return meta, lines[i:], nil
+}
+
+// 使用 "// more" 之前的内容或者第一个章节之前的前言作为摘要
+func splitSummary(lines []string) []string {
+	for i, line := range lines {
+		if ASCIIDOC_MORE.MatchString(line) {
+			return lines[:i]
+		}
+	}
+	for i, line := range lines {
+		if ASCIIDOC_SECTION.MatchString(line) {
+			for _, l := range lines[:i] {
+				if strings.TrimSpace(l) != "" {
+					return lines[:i]
+				}
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (m *asciidoc) Read(file string) (page.Meta, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	meta, lines, err := readMeta(f)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{conf: m.conf, meta: meta, hooks: m.hooks}
+	lines, err = r.include(filepath.Dir(file), lines, 0)
+	if err != nil {
+		return nil, err
+	}
+	// 被引用的文件修改后需要重新构建
+	for _, file := range r.files {
+		m.conf.Watch(file)
+	}
+
+	content := r.render(lines)
+	if summary := splitSummary(lines); summary != nil {
+		meta["summary"] = r.render(summary)
+	} else {
+		meta["summary"] = m.conf.GetSummary(content)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	meta["content"] = content
+	return meta, nil
+}
+
+func (m *asciidoc) HTML(data []byte) (string, error) {
+	r := &renderer{conf: m.conf}
+	out := r.render(strings.Split(string(data), "\n"))
+	if r.err != nil {
+		return "", r.err
+	}
+	return out, nil
+}
+
+func New(conf config.Config, theme theme.Theme) page.Reader {
+	return &asciidoc{
+		conf:  conf,
+		hooks: page.LookupRenderHooks(theme, "heading", "codeblock"),
+	}
+}
+
+func NewPongo2Filter(conf config.Config) pongo2.FilterFunction {
+	r := &asciidoc{conf: conf}
+	return func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		v, ok := in.Interface().(string)
+		if !ok {
+			return nil, &pongo2.Error{
+				Sender:    "filter:asciidoc",
+				OrigError: errors.New("filter input argument must be of type 'string'"),
+			}
+		}
+		out, err := r.HTML([]byte(v))
+		if err != nil {
+			return nil, &pongo2.Error{
+				Sender:    "filter:asciidoc",
+				OrigError: err,
+			}
+		}
+		return pongo2.AsValue(out), nil
+	}
+}
+
+func init() {
+	for _, ext := range ASCIIDOC_EXTS {
+		page.RegisterWithTheme(ext, New)
+	}
+	template.RegisterConfigFilter("asciidoc", NewPongo2Filter)
+}
@@ -0,0 +1,122 @@
+package asciidoc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeta(t *testing.T) {
+	text := `= Hello AsciiDoc
+Jane Doe <jane@example.com>
+v1.0, 2023-02-24
+:tags: snow, adoc
+:product: Snow
+
+preamble
+`
+	meta, lines, err := readMeta(strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello AsciiDoc", meta.GetString("title"))
+	assert.Equal(t, "Jane Doe", meta.GetString("author"))
+	assert.Equal(t, "jane@example.com", meta.GetString("email"))
+	assert.Equal(t, "2023-02-24", meta.GetString("date"))
+	assert.Equal(t, []string{"snow", "adoc"}, meta.GetSlice("tags"))
+	assert.Equal(t, "Snow", meta.GetString("product"))
+	assert.Equal(t, []string{"", "preamble"}, lines)
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n// tag::main[]\nfunc main() {}\n// end::main[]\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "doc.adoc"), []byte(`= Doc
+:product: Snow
+
+The *preamble* for {product}.
+
+== Getting Started
+
+See https://example.com[the site] and <<_getting_started,here>>, <<other.asciidoc#intro,other>>.
+
+* one
+** nested
+* two
+
+[source,go]
+----
+include::main.go[tag=main]
+----
+`), 0644)
+
+	conf := config.DefaultConfig()
+	conf.Set("content_highlight_style", "")
+
+	m := &asciidoc{conf: conf}
+	meta, err := m.Read(filepath.Join(dir, "doc.adoc"))
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>The <strong>preamble</strong> for Snow.</p>\n", meta.GetString("summary"))
+	assert.Equal(t, `<p>The <strong>preamble</strong> for Snow.</p>
+<h2 id="_getting_started">Getting Started</h2>
+<p>See <a href="https://example.com">the site</a> and <a href="#_getting_started">here</a>, <a href="other.asciidoc#intro">other</a>.</p>
+<ul>
+<li>one<ul>
+<li>nested</li>
+</ul>
+</li>
+<li>two</li>
+</ul>
+<pre><code class="language-go">func main() {}
+</code></pre>`, meta.GetString("content"))
+
+	os.WriteFile(filepath.Join(dir, "err.adoc"), []byte("include::unknown.go[]\n"), 0644)
+	_, err = m.Read(filepath.Join(dir, "err.adoc"))
+	assert.NotNil(t, err)
+}
+
+func TestRender(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("content_highlight_style", "")
+
+	tests := []struct {
+		text   string
+		expect string
+	}{
+		{
+			text:   "[cols=\"1,2\",options=\"header\"]\n|===\n|Name |Desc\n|a\n|b\n|===",
+			expect: "<table>\n<thead>\n<tr><th>Name</th><th>Desc</th></tr>\n</thead>\n<tbody>\n<tr><td>a</td><td>b</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			text:   "[cols=\"2*\"]\n|===\n|x |\n|1 |2\n|===",
+			expect: "<table>\n<tbody>\n<tr><td>x</td><td></td></tr>\n<tr><td>1</td><td>2</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			text:   "|===\n|a |b\n\n|1 |2\n|===",
+			expect: "<table>\n<thead>\n<tr><th>a</th><th>b</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td>2</td></tr>\n</tbody>\n</table>\n",
+		},
+		{
+			text:   "CPU:: The brain\nRAM::\n* fast\n* small",
+			expect: "<dl>\n<dt>CPU</dt>\n<dd>The brain</dd>\n<dt>RAM</dt>\n<dd>\n<ul>\n<li>fast</li>\n<li>small</li>\n</ul>\n</dd>\n</dl>\n",
+		},
+		{
+			text:   "* one\n+\nmore *text*\n+\n----\na\n\nb\n----\n* two",
+			expect: "<ul>\n<li>one\n<p>more <strong>text</strong></p>\n<pre>a\n\nb</pre>\n</li>\n<li>two</li>\n</ul>\n",
+		},
+		{
+			text:   ":name: Snow\n\n{name}{nbsp}{version}\n\n:name!:\n\n{name}",
+			expect: "<p>Snow 1.0</p>\n<p>{name}</p>\n",
+		},
+		{
+			text:   "WARNING: be careful",
+			expect: "<div class=\"admonition warning\"><p class=\"admonition-title\">WARNING</p><p>be careful</p></div>\n",
+		},
+	}
+	for _, test := range tests {
+		r := &renderer{conf: conf, meta: page.Meta{"name": "Doc", "version": "1.0"}}
+		assert.Equal(t, test.expect, r.render(strings.Split(test.text, "\n")), test.text)
+	}
+}
@@ -0,0 +1,712 @@
+package asciidoc
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme/template"
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+)
+
+var (
+	ASCIIDOC_HEADING    = regexp.MustCompile(`^(={2,6})\s+(.+?)\s*$`)
+	ASCIIDOC_ANCHOR     = regexp.MustCompile(`^\[\[([^\]]+)\]\]$`)
+	ASCIIDOC_ATTRIBUTES = regexp.MustCompile(`^\[([^\[\]]*)\]$`)
+	ASCIIDOC_TITLE      = regexp.MustCompile(`^\.([^\s.].*)$`)
+	ASCIIDOC_INCLUDE    = regexp.MustCompile(`^include::([^\[]+)\[(.*)\]$`)
+	ASCIIDOC_IMAGE      = regexp.MustCompile(`^image::([^\[]+)\[(.*)\]$`)
+	ASCIIDOC_LIST       = regexp.MustCompile(`^\s*(\*{1,5}|-|\.{1,5})\s+(.*)$`)
+	ASCIIDOC_ADMONITION = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	ASCIIDOC_DELIMITER  = regexp.MustCompile(`^(-{4,}|\.{4,}|_{4,}|={4,}|\*{4,}|\+{4,}|\|===)$`)
+	ASCIIDOC_DLIST      = regexp.MustCompile(`^(\S.*?)(::|;;)(?:\s+(.*))?$`)
+	ASCIIDOC_ENTRY      = regexp.MustCompile(`^:([\w-]+)(!?):(?:\s+(.*))?$`)
+
+	ASCIIDOC_CODE         = regexp.MustCompile("`([^`]+)`")
+	ASCIIDOC_STRONG       = regexp.MustCompile(`(^|[^\w*])\*([^\s*](?:[^*]*[^\s*])?)\*($|[^\w*])`)
+	ASCIIDOC_EMPHASIS     = regexp.MustCompile(`(^|[^\w_])_([^\s_](?:[^_]*[^\s_])?)_($|[^\w_])`)
+	ASCIIDOC_URL          = regexp.MustCompile(`(https?://[^\s\[<]+)\[([^\]]*)\]`)
+	ASCIIDOC_LINK         = regexp.MustCompile(`(?:link|xref):([^\s\[]+)\[([^\]]*)\]`)
+	ASCIIDOC_XREF         = regexp.MustCompile(`&lt;&lt;([^,&]+?)(?:,\s*(.*?))?&gt;&gt;`)
+	ASCIIDOC_AUTOURL      = regexp.MustCompile(`(^|\s)(https?://[^\s<\[]*[^\s<\[.,;:!?)])`)
+	ASCIIDOC_INLINE_IMAGE = regexp.MustCompile(`image:([^\s\[:][^\s\[]*)\[([^\]]*)\]`)
+	ASCIIDOC_ATTRIBUTE    = regexp.MustCompile(`\{([\w-]+)\}`)
+	ASCIIDOC_ID           = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+	ASCIIDOC_MAX_INCLUDES = 64
+	ASCIIDOC_EXTS         = []string{".adoc", ".asciidoc", ".asc"}
+
+	// 内置的属性
+	ASCIIDOC_INTRINSICS = map[string]string{
+		"empty": "",
+		"sp":    " ",
+		"nbsp":  "\u00a0",
+		"zwsp":  "\u200b",
+		"amp":   "&",
+		"lt":    "<",
+		"gt":    ">",
+		"vbar":  "|",
+		"plus":  "+",
+	}
+)
+
+type (
+	block struct {
+		id    string
+		title string
+		style string
+		args  []string
+		attrs map[string]interface{}
+	}
+	renderer struct {
+		conf  config.Config
+		meta  page.Meta
+		hooks map[string]template.Writer
+		files []string
+		// 正文中定义的属性, 比如 :name: value, 取消的属性为nil
+		attrs map[string]interface{}
+		err   error
+	}
+)
+
+func (r *renderer) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *renderer) renderHook(kind string, vars map[string]interface{}) (string, bool) {
+	tpl, ok := r.hooks[kind]
+	if !ok {
+		return "", false
+	}
+	if _, ok := vars["attributes"]; !ok {
+		vars["attributes"] = make(map[string]interface{})
+	}
+	vars["page"] = r.meta
+
+	out, err := tpl.Execute(vars)
+	if err != nil {
+		r.setErr(err)
+		return "", true
+	}
+	return out, true
+}
+
+// [source,go,linenums] 或者 [quote, author]
+func parseBlockAttributes(b *block, text string) {
+	for i, arg := range utils.SplitTrim(text, ",") {
+		if kv := strings.SplitN(arg, "=", 2); len(kv) == 2 {
+			b.attrs[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			continue
+		}
+		if i == 0 {
+			style := arg
+			if idx := strings.Index(style, "#"); idx >= 0 {
+				b.id = style[idx+1:]
+				style = style[:idx]
+			}
+			b.style = style
+			continue
+		}
+		b.args = append(b.args, arg)
+	}
+}
+
+// include::main.go[lines=10..20,tag=main]
+func (r *renderer) include(dir string, lines []string, count int) ([]string, error) {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		match := ASCIIDOC_INCLUDE.FindStringSubmatch(line)
+		if match == nil {
+			result = append(result, line)
+			continue
+		}
+		count++
+		if count > ASCIIDOC_MAX_INCLUDES {
+			return nil, fmt.Errorf("too many includes")
+		}
+		b := &block{attrs: make(map[string]interface{})}
+		parseBlockAttributes(b, "_,"+match[2])
+
+		file := match[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		lns, tag := "", ""
+		if v, ok := b.attrs["lines"].(string); ok {
+			lns = strings.ReplaceAll(v, "..", "-")
+		}
+		if v, ok := b.attrs["tag"].(string); ok {
+			tag = v
+		}
+		text, err := utils.ReadFileLines(file, lns, tag)
+		if err != nil {
+			return nil, err
+		}
+		r.files = append(r.files, file)
+
+		included, err := r.include(filepath.Dir(file), strings.Split(strings.TrimSuffix(text, "\n"), "\n"), count)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, included...)
+	}
+	return result, nil
+}
+
+// 正文中的属性 > 文档头部的属性 > 内置的属性
+func (r *renderer) attribute(name string) (string, bool) {
+	if v, ok := r.attrs[name]; ok {
+		s, ok := v.(string)
+		return s, ok
+	}
+	if r.meta != nil {
+		if v, ok := r.meta[name].(string); ok {
+			return v, true
+		}
+	}
+	v, ok := ASCIIDOC_INTRINSICS[name]
+	return v, ok
+}
+
+// :name: value 设置属性, :name!: 取消属性
+func (r *renderer) setAttribute(name string, value interface{}) {
+	if r.attrs == nil {
+		r.attrs = make(map[string]interface{})
+	}
+	r.attrs[name] = value
+}
+
+func (r *renderer) inline(text string) string {
+	text = ASCIIDOC_ATTRIBUTE.ReplaceAllStringFunc(text, func(s string) string {
+		if v, ok := r.attribute(s[1 : len(s)-1]); ok {
+			return v
+		}
+		return s
+	})
+	hardbreak := strings.HasSuffix(text, " +")
+	if hardbreak {
+		text = strings.TrimSuffix(text, " +")
+	}
+
+	var (
+		b    strings.Builder
+		last = 0
+	)
+	for _, loc := range ASCIIDOC_CODE.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(r.format(text[last:loc[0]]))
+		b.WriteString("<code>")
+		b.WriteString(html.EscapeString(text[loc[2]:loc[3]]))
+		b.WriteString("</code>")
+		last = loc[1]
+	}
+	b.WriteString(r.format(text[last:]))
+	if hardbreak {
+		b.WriteString("<br>")
+	}
+	return b.String()
+}
+
+func (r *renderer) format(text string) string {
+	text = html.EscapeString(text)
+	text = ASCIIDOC_INLINE_IMAGE.ReplaceAllString(text, `<img src="$1" alt="$2">`)
+	text = ASCIIDOC_URL.ReplaceAllStringFunc(text, func(s string) string {
+		match := ASCIIDOC_URL.FindStringSubmatch(s)
+		return link(match[1], match[2])
+	})
+	text = ASCIIDOC_LINK.ReplaceAllStringFunc(text, func(s string) string {
+		match := ASCIIDOC_LINK.FindStringSubmatch(s)
+		return link(match[1], match[2])
+	})
+	text = ASCIIDOC_XREF.ReplaceAllStringFunc(text, func(s string) string {
+		match := ASCIIDOC_XREF.FindStringSubmatch(s)
+		label := match[2]
+		if label == "" {
+			label = match[1]
+		}
+		// <<other.adoc#id,text>>
+		file := strings.SplitN(match[1], "#", 2)[0]
+		for _, ext := range ASCIIDOC_EXTS {
+			if strings.HasSuffix(file, ext) {
+				return link(match[1], label)
+			}
+		}
+		return link("#"+match[1], label)
+	})
+	text = ASCIIDOC_AUTOURL.ReplaceAllString(text, `$1<a href="$2">$2</a>`)
+	text = ASCIIDOC_STRONG.ReplaceAllString(text, `$1<strong>$2</strong>$3`)
+	text = ASCIIDOC_EMPHASIS.ReplaceAllString(text, `$1<em>$2</em>$3`)
+	return text
+}
+
+func link(href, text string) string {
+	if text == "" {
+		text = href
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, href, text)
+}
+
+// 和asciidoctor一致, 标题的默认id以下划线开头
+func headingID(title string) string {
+	return "_" + strings.Trim(ASCIIDOC_ID.ReplaceAllString(strings.ToLower(title), "_"), "_")
+}
+
+func (r *renderer) highlightCodeBlock(source, lang string) string {
+	out, err := utils.Highlight(source, lang, r.conf.GetHighlightStyle(), nil)
+	if err != nil {
+		r.setErr(err)
+	}
+	return out
+}
+
+func (r *renderer) renderHeading(w *strings.Builder, b *block, level int, title string) {
+	anchor := b.id
+	if anchor == "" {
+		anchor = headingID(title)
+	}
+	text := r.inline(title)
+	out := fmt.Sprintf("<h%d id=\"%s\">%s</h%d>\n", level, anchor, text, level)
+	if s, ok := r.renderHook("heading", map[string]interface{}{
+		"level":  level,
+		"anchor": anchor,
+		"text":   text,
+		"html":   out,
+	}); ok {
+		out = s
+	}
+	w.WriteString(out)
+}
+
+func (r *renderer) renderCodeBlock(w *strings.Builder, b *block, code string) {
+	lang := ""
+	if len(b.args) > 0 {
+		lang = b.args[0]
+	} else if v, ok := r.attribute("source-language"); ok {
+		lang = v
+	}
+	out := r.highlightCodeBlock(code, lang)
+	if s, ok := r.renderHook("codeblock", map[string]interface{}{
+		"lang":       lang,
+		"code":       code,
+		"html":       out,
+		"attributes": b.attrs,
+	}); ok {
+		out = s
+	}
+	r.renderTitle(w, b)
+	w.WriteString(out)
+}
+
+func (r *renderer) renderTitle(w *strings.Builder, b *block) {
+	if b.title != "" {
+		fmt.Fprintf(w, "<div class=\"title\">%s</div>\n", r.inline(b.title))
+	}
+}
+
+// cols="1,2" -> 2, cols="3*" -> 3, cols=3 -> 3
+func tableCols(b *block) int {
+	spec := cast.ToString(b.attrs["cols"])
+	if spec == "" {
+		return 0
+	}
+	fields := utils.SplitTrim(spec, ",")
+	if len(fields) == 1 && !strings.Contains(spec, ",") {
+		if n, err := strconv.Atoi(spec); err == nil {
+			return n
+		}
+	}
+	cols := 0
+	for _, field := range fields {
+		if idx := strings.Index(field, "*"); idx > 0 {
+			if n, err := strconv.Atoi(field[:idx]); err == nil {
+				cols += n
+				continue
+			}
+		}
+		cols++
+	}
+	return cols
+}
+
+// [%header] 或者 [options="header"]
+func tableOption(b *block, name string) bool {
+	if strings.Contains(b.style, "%"+name) {
+		return true
+	}
+	for _, key := range []string{"options", "opts"} {
+		if utils.CheckInList(utils.SplitTrim(cast.ToString(b.attrs[key]), ","), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *renderer) renderTable(w *strings.Builder, b *block, lines []string) {
+	var (
+		cells = make([]string, 0)
+		cols  = tableCols(b)
+		// 第一行之后是空行时作为表头
+		header = len(lines) > 1 && strings.TrimSpace(lines[0]) != "" && strings.TrimSpace(lines[1]) == ""
+	)
+	if tableOption(b, "header") {
+		header = true
+	} else if tableOption(b, "noheader") {
+		header = false
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// 没有以|开头的行属于上一个单元格
+		if !strings.HasPrefix(line, "|") {
+			if len(cells) > 0 {
+				cells[len(cells)-1] = strings.TrimSpace(cells[len(cells)-1] + " " + line)
+			}
+			continue
+		}
+		row := strings.Split(line[1:], "|")
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+		if cols == 0 {
+			cols = len(row)
+		}
+		cells = append(cells, row...)
+	}
+	if cols == 0 {
+		return
+	}
+	r.renderTitle(w, b)
+	w.WriteString("<table>\n")
+	for i := 0; i < len(cells); i += cols {
+		tag := "td"
+		if i == 0 && header {
+			tag = "th"
+			w.WriteString("<thead>\n")
+		} else if i == 0 || (i == cols && header) {
+			w.WriteString("<tbody>\n")
+		}
+		w.WriteString("<tr>")
+		for j := i; j < i+cols && j < len(cells); j++ {
+			fmt.Fprintf(w, "<%s>%s</%s>", tag, r.inline(cells[j]), tag)
+		}
+		w.WriteString("</tr>\n")
+		if i == 0 && header {
+			w.WriteString("</thead>\n")
+		}
+	}
+	if !header || len(cells) > cols {
+		w.WriteString("</tbody>\n")
+	}
+	w.WriteString("</table>\n")
+}
+
+// 使用+连接的块属于上一个列表项
+func listContinuation(lines []string, i int) ([]string, int) {
+	inner := make([]string, 0)
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "+" {
+		i++
+		start := i
+		for i < len(lines) {
+			trimmed := strings.TrimSpace(lines[i])
+			if !ASCIIDOC_ATTRIBUTES.MatchString(trimmed) && !ASCIIDOC_ANCHOR.MatchString(trimmed) && !ASCIIDOC_TITLE.MatchString(trimmed) {
+				break
+			}
+			i++
+		}
+		if i < len(lines) && ASCIIDOC_DELIMITER.MatchString(strings.TrimSpace(lines[i])) {
+			delimiter := strings.TrimSpace(lines[i])
+			for i = i + 1; i < len(lines) && strings.TrimSpace(lines[i]) != delimiter; i++ {
+			}
+			if i < len(lines) {
+				i++
+			}
+		} else {
+			for i < len(lines) && !isListBoundary(lines[i]) {
+				i++
+			}
+		}
+		inner = append(inner, lines[start:i]...)
+		inner = append(inner, "")
+	}
+	return inner, i
+}
+
+func isListBoundary(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || trimmed == "+" || ASCIIDOC_LIST.MatchString(line) || ASCIIDOC_DLIST.MatchString(trimmed)
+}
+
+func (r *renderer) renderList(w *strings.Builder, lines []string, i int) int {
+	stack := make([]string, 0)
+	tag := func(marker string) string {
+		if strings.HasPrefix(marker, ".") {
+			return "ol"
+		}
+		return "ul"
+	}
+	for i < len(lines) {
+		match := ASCIIDOC_LIST.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		marker, text := match[1], match[2]
+
+		j := i + 1
+		for j < len(lines) && !isListBoundary(lines[j]) {
+			text += " " + strings.TrimSpace(lines[j])
+			j++
+		}
+
+		idx := -1
+		for k, m := range stack {
+			if m == marker {
+				idx = k
+				break
+			}
+		}
+		if idx < 0 {
+			stack = append(stack, marker)
+			fmt.Fprintf(w, "<%s>\n", tag(marker))
+		} else {
+			for len(stack)-1 > idx {
+				fmt.Fprintf(w, "</li>\n</%s>\n", tag(stack[len(stack)-1]))
+				stack = stack[:len(stack)-1]
+			}
+			w.WriteString("</li>\n")
+		}
+		fmt.Fprintf(w, "<li>%s", r.inline(text))
+		if inner, k := listContinuation(lines, j); k > j {
+			w.WriteString("\n")
+			r.renderBlocks(w, inner)
+			j = k
+		}
+
+		// 列表项之间允许空行
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" && j+1 < len(lines) && ASCIIDOC_LIST.MatchString(lines[j+1]) {
+			j++
+		}
+		i = j
+	}
+	for len(stack) > 0 {
+		fmt.Fprintf(w, "</li>\n</%s>\n", tag(stack[len(stack)-1]))
+		stack = stack[:len(stack)-1]
+	}
+	return i
+}
+
+// Term:: 描述, 描述可以在下一行, 也可以是一个列表
+func (r *renderer) renderDescriptionList(w *strings.Builder, lines []string, i int) int {
+	w.WriteString("<dl>\n")
+	for i < len(lines) {
+		match := ASCIIDOC_DLIST.FindStringSubmatch(strings.TrimSpace(lines[i]))
+		if match == nil {
+			break
+		}
+		fmt.Fprintf(w, "<dt>%s</dt>\n", r.inline(match[1]))
+
+		text := match[3]
+		j := i + 1
+		for j < len(lines) && !isListBoundary(lines[j]) && !ASCIIDOC_DLIST.MatchString(strings.TrimSpace(lines[j])) {
+			text += " " + strings.TrimSpace(lines[j])
+			j++
+		}
+		text = strings.TrimSpace(text)
+		if j < len(lines) && ASCIIDOC_LIST.MatchString(lines[j]) {
+			fmt.Fprintf(w, "<dd>%s\n", r.inline(text))
+			j = r.renderList(w, lines, j)
+			w.WriteString("</dd>\n")
+		} else if text != "" {
+			fmt.Fprintf(w, "<dd>%s</dd>\n", r.inline(text))
+		}
+		// 描述列表之间允许空行
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" && j+1 < len(lines) && ASCIIDOC_DLIST.MatchString(strings.TrimSpace(lines[j+1])) {
+			j++
+		}
+		i = j
+	}
+	w.WriteString("</dl>\n")
+	return i
+}
+
+func (r *renderer) renderParagraph(w *strings.Builder, b *block, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = r.inline(strings.TrimSpace(line))
+	}
+	text := strings.Join(texts, "\n")
+
+	style := b.style
+	if match := ASCIIDOC_ADMONITION.FindStringSubmatch(lines[0]); match != nil {
+		style = match[1]
+		texts[0] = r.inline(match[2])
+		text = strings.Join(texts, "\n")
+	}
+	switch style {
+	case "NOTE", "TIP", "IMPORTANT", "WARNING", "CAUTION":
+		fmt.Fprintf(w, "<div class=\"admonition %s\"><p class=\"admonition-title\">%s</p><p>%s</p></div>\n", strings.ToLower(style), style, text)
+	case "source":
+		r.renderCodeBlock(w, b, strings.Join(lines, "\n")+"\n")
+	default:
+		r.renderTitle(w, b)
+		fmt.Fprintf(w, "<p>%s</p>\n", text)
+	}
+}
+
+func (r *renderer) renderDelimited(w *strings.Builder, b *block, delimiter string, lines []string) {
+	switch delimiter[0] {
+	case '-':
+		if b.style == "source" {
+			r.renderCodeBlock(w, b, strings.Join(lines, "\n")+"\n")
+			return
+		}
+		r.renderTitle(w, b)
+		fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(strings.Join(lines, "\n")))
+	case '.':
+		r.renderTitle(w, b)
+		fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(strings.Join(lines, "\n")))
+	case '_':
+		w.WriteString("<blockquote>\n")
+		r.renderBlocks(w, lines)
+		if len(b.args) > 0 {
+			fmt.Fprintf(w, "<footer>%s</footer>\n", r.inline(strings.Join(b.args, ", ")))
+		}
+		w.WriteString("</blockquote>\n")
+	case '=':
+		switch b.style {
+		case "NOTE", "TIP", "IMPORTANT", "WARNING", "CAUTION":
+			fmt.Fprintf(w, "<div class=\"admonition %s\"><p class=\"admonition-title\">%s</p>\n", strings.ToLower(b.style), b.style)
+		default:
+			w.WriteString("<div class=\"exampleblock\">\n")
+			r.renderTitle(w, b)
+		}
+		r.renderBlocks(w, lines)
+		w.WriteString("</div>\n")
+	case '*':
+		w.WriteString("<aside class=\"sidebarblock\">\n")
+		r.renderTitle(w, b)
+		r.renderBlocks(w, lines)
+		w.WriteString("</aside>\n")
+	case '+':
+		w.WriteString(strings.Join(lines, "\n"))
+		w.WriteString("\n")
+	case '|':
+		r.renderTable(w, b, lines)
+	}
+}
+
+func (r *renderer) renderBlocks(w *strings.Builder, lines []string) {
+	var (
+		b         = &block{attrs: make(map[string]interface{})}
+		paragraph = make([]string, 0)
+	)
+	flush := func() {
+		r.renderParagraph(w, b, paragraph)
+		paragraph = paragraph[:0]
+		b = &block{attrs: make(map[string]interface{})}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if len(paragraph) > 0 {
+			if trimmed == "" {
+				flush()
+			} else {
+				paragraph = append(paragraph, line)
+			}
+			continue
+		}
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "////"):
+			for i = i + 1; i < len(lines) && strings.TrimSpace(lines[i]) != trimmed; i++ {
+			}
+			continue
+		case strings.HasPrefix(trimmed, "//"):
+			continue
+		case trimmed == "'''" || trimmed == "<<<":
+			if trimmed == "'''" {
+				w.WriteString("<hr>\n")
+			}
+			continue
+		}
+		if match := ASCIIDOC_ENTRY.FindStringSubmatch(trimmed); match != nil {
+			if match[2] == "!" {
+				r.setAttribute(match[1], nil)
+			} else {
+				r.setAttribute(match[1], match[3])
+			}
+			continue
+		}
+		if match := ASCIIDOC_ANCHOR.FindStringSubmatch(trimmed); match != nil {
+			b.id = match[1]
+			continue
+		}
+		if match := ASCIIDOC_ATTRIBUTES.FindStringSubmatch(trimmed); match != nil {
+			parseBlockAttributes(b, match[1])
+			continue
+		}
+		if match := ASCIIDOC_TITLE.FindStringSubmatch(trimmed); match != nil {
+			b.title = match[1]
+			continue
+		}
+		if match := ASCIIDOC_HEADING.FindStringSubmatch(line); match != nil {
+			r.renderHeading(w, b, len(match[1]), match[2])
+			b = &block{attrs: make(map[string]interface{})}
+			continue
+		}
+		if ASCIIDOC_DELIMITER.MatchString(trimmed) {
+			j := i + 1
+			for j < len(lines) && strings.TrimSpace(lines[j]) != trimmed {
+				j++
+			}
+			r.renderDelimited(w, b, trimmed, lines[i+1:j])
+			b = &block{attrs: make(map[string]interface{})}
+			i = j
+			continue
+		}
+		if match := ASCIIDOC_IMAGE.FindStringSubmatch(trimmed); match != nil {
+			r.renderTitle(w, b)
+			alt := utils.SplitTrim(match[2], ",")
+			if len(alt) == 0 {
+				alt = []string{""}
+			}
+			fmt.Fprintf(w, "<img src=\"%s\" alt=\"%s\">\n", match[1], html.EscapeString(alt[0]))
+			b = &block{attrs: make(map[string]interface{})}
+			continue
+		}
+		if ASCIIDOC_LIST.MatchString(line) {
+			r.renderTitle(w, b)
+			i = r.renderList(w, lines, i) - 1
+			b = &block{attrs: make(map[string]interface{})}
+			continue
+		}
+		if ASCIIDOC_DLIST.MatchString(trimmed) {
+			r.renderTitle(w, b)
+			i = r.renderDescriptionList(w, lines, i) - 1
+			b = &block{attrs: make(map[string]interface{})}
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+}
+
+func (r *renderer) render(lines []string) string {
+	var w strings.Builder
+	r.renderBlocks(&w, lines)
+	return w.String()
+}
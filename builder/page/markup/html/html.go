@@ -3,13 +3,20 @@ package html
 import (
 	"bytes"
 	"io"
+	"os"
+	"regexp"
 	"strings"
 
 	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme"
 	"github.com/honmaple/snow/config"
 	"golang.org/x/net/html"
 )
 
+var (
+	HTML_MORE = regexp.MustCompile(`<!--\s*(?i:more)\s*-->`)
+)
+
 type htmlReader struct {
 	conf config.Config
 }
@@ -76,25 +83,47 @@ func parseMeta(meta page.Meta, n *html.Node) error {
 }
 
 func readMeta(r io.Reader) (page.Meta, error) {
+	meta, r, err := page.ReadFrontMatter(r)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = make(page.Meta)
+	}
 	doc, err := html.Parse(r)
 	if err != nil {
 		return nil, err
 	}
-	meta := make(page.Meta)
 	if err := parseMeta(meta, doc); err != nil {
 		return nil, err
 	}
 	return meta, nil
 }
 
-func (s *htmlReader) Read(r io.Reader) (page.Meta, error) {
-	return readMeta(r)
+func (s *htmlReader) Read(file string) (page.Meta, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	meta, err := readMeta(f)
+	if err != nil {
+		return nil, err
+	}
+	content := meta.GetString("content")
+	if loc := HTML_MORE.FindStringIndex(content); loc != nil {
+		meta["summary"] = strings.TrimSpace(content[:loc[0]])
+	} else {
+		meta["summary"] = s.conf.GetSummary(content)
+	}
+	return meta, nil
 }
 
-func New(conf config.Config) page.Reader {
+func New(conf config.Config, theme theme.Theme) page.Reader {
 	return &htmlReader{conf}
 }
 
 func init() {
-	page.Register(".html", New)
+	page.RegisterWithTheme(".html", New)
 }
@@ -1,9 +1,12 @@
 package html
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/honmaple/snow/config"
 	"github.com/honmaple/snow/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -54,3 +57,30 @@ func TestMeta(t *testing.T) {
 `
 	assertFunc(t, text)
 }
+
+func TestFrontMatter(t *testing.T) {
+	text := `---
+title: aaa
+tags: [snow, html]
+---
+<p>summary</p>
+<!--more-->
+<p>content</p>
+`
+	file := filepath.Join(t.TempDir(), "test.html")
+	assert.Nil(t, os.WriteFile(file, []byte(text), 0644))
+
+	r := &htmlReader{conf: config.DefaultConfig()}
+	meta, err := r.Read(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "aaa", meta.GetString("title"))
+	assert.Equal(t, []string{"snow", "html"}, meta.GetSlice("tags"))
+	assert.Equal(t, "<p>summary</p>", meta.GetString("summary"))
+	assert.Equal(t, "<p>summary</p>\n<!--more-->\n<p>content</p>", meta.GetString("content"))
+
+	text = "+++\ntitle = \"bbb\"\n+++\n<p>content</p>"
+	meta, err = readMeta(strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "bbb", meta.GetString("title"))
+	assert.Equal(t, "<p>content</p>", meta.GetString("content"))
+}
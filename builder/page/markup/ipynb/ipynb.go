@@ -0,0 +1,244 @@
+package ipynb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/page/markup/markdown"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+)
+
+var (
+	IPYNB_ANSI = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+	// 按照优先级选择输出格式
+	IPYNB_MIMES = []string{"text/html", "image/svg+xml", "image/png", "image/jpeg", "image/gif", "text/markdown", "text/plain"}
+)
+
+type (
+	// 兼容字符串和字符串数组
+	source string
+
+	output struct {
+		OutputType string            `json:"output_type"`
+		Name       string            `json:"name"`
+		Text       source            `json:"text"`
+		Data       map[string]source `json:"data"`
+		Traceback  []string          `json:"traceback"`
+	}
+	cell struct {
+		CellType string `json:"cell_type"`
+		Source   source `json:"source"`
+		Metadata struct {
+			Tags []string `json:"tags"`
+		} `json:"metadata"`
+		Outputs []output `json:"outputs"`
+	}
+	notebook struct {
+		Cells    []cell                 `json:"cells"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+)
+
+func (s *source) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*s = source(strings.Join(lines, ""))
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*s = source(str)
+	return nil
+}
+
+type (
+	htmlRenderer interface {
+		HTMLWithDir(string, []byte, bool) (string, error)
+	}
+	ipynb struct {
+		conf     config.Config
+		markdown htmlRenderer
+	}
+)
+
+func (c *cell) hasTag(tags []string) bool {
+	for _, tag := range c.Metadata.Tags {
+		if utils.CheckInList(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (nb *notebook) language() string {
+	if v, ok := nb.Metadata["kernelspec"].(map[string]interface{}); ok {
+		if lang, ok := v["language"].(string); ok && lang != "" {
+			return lang
+		}
+	}
+	if v, ok := nb.Metadata["language_info"].(map[string]interface{}); ok {
+		if lang, ok := v["name"].(string); ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+func (m *ipynb) highlight(code, lang string) string {
+	out, err := utils.Highlight(code, lang, m.conf.GetHighlightStyle(), nil)
+	if err != nil {
+		return fmt.Sprintf("<pre><code class=\"language-%s\">%s</code></pre>", lang, html.EscapeString(code))
+	}
+	return out
+}
+
+func (m *ipynb) renderOutput(w *strings.Builder, dir string, out output) error {
+	switch out.OutputType {
+	case "stream":
+		fmt.Fprintf(w, "<pre class=\"output-%s\">%s</pre>\n", out.Name, html.EscapeString(string(out.Text)))
+	case "error":
+		traceback := IPYNB_ANSI.ReplaceAllString(strings.Join(out.Traceback, "\n"), "")
+		fmt.Fprintf(w, "<pre class=\"output-error\">%s</pre>\n", html.EscapeString(traceback))
+	case "execute_result", "display_data":
+		mime, data := "", source("")
+		for _, k := range IPYNB_MIMES {
+			if v, ok := out.Data[k]; ok {
+				mime, data = k, v
+				break
+			}
+		}
+		switch mime {
+		case "text/html":
+			w.WriteString(string(data))
+			w.WriteString("\n")
+		case "image/svg+xml":
+			fmt.Fprintf(w, "<img src=\"data:%s;base64,%s\"/>\n", mime, base64.StdEncoding.EncodeToString([]byte(data)))
+		case "text/markdown":
+			out, err := m.markdown.HTMLWithDir(dir, []byte(data), false)
+			if err != nil {
+				return err
+			}
+			w.WriteString(out)
+		case "text/plain":
+			fmt.Fprintf(w, "<pre class=\"output-result\">%s</pre>\n", html.EscapeString(string(data)))
+		case "image/png", "image/jpeg", "image/gif":
+			// 图片已经是base64编码
+			fmt.Fprintf(w, "<img src=\"data:%s;base64,%s\"/>\n", mime, strings.ReplaceAll(string(data), "\n", ""))
+		}
+	}
+	return nil
+}
+
+// dir为notebook所在的目录, markdown中引用的文件使用相对于dir的路径
+func (m *ipynb) render(dir string, nb *notebook) (string, error) {
+	var (
+		w    strings.Builder
+		lang = nb.language()
+
+		hideInput  = m.conf.GetStringSlice("ipynb_hide_input_tags")
+		hideOutput = m.conf.GetStringSlice("ipynb_hide_output_tags")
+		hideCell   = m.conf.GetStringSlice("ipynb_hide_cell_tags")
+	)
+	for _, c := range nb.Cells {
+		if c.hasTag(hideCell) {
+			continue
+		}
+		switch c.CellType {
+		case "markdown":
+			out, err := m.markdown.HTMLWithDir(dir, []byte(c.Source), false)
+			if err != nil {
+				return "", err
+			}
+			w.WriteString(out)
+		case "code":
+			w.WriteString("<div class=\"cell code-cell\">\n")
+			if !c.hasTag(hideInput) && strings.TrimSpace(string(c.Source)) != "" {
+				w.WriteString("<div class=\"input\">\n")
+				w.WriteString(m.highlight(string(c.Source), lang))
+				w.WriteString("</div>\n")
+			}
+			if !c.hasTag(hideOutput) && len(c.Outputs) > 0 {
+				w.WriteString("<div class=\"output\">\n")
+				for _, out := range c.Outputs {
+					if err := m.renderOutput(&w, dir, out); err != nil {
+						return "", err
+					}
+				}
+				w.WriteString("</div>\n")
+			}
+			w.WriteString("</div>\n")
+		case "raw":
+			w.WriteString(string(c.Source))
+		}
+	}
+	return w.String(), nil
+}
+
+func readMeta(nb *notebook) (page.Meta, error) {
+	meta := make(page.Meta)
+	for k, v := range nb.Metadata {
+		switch k {
+		case "kernelspec", "language_info":
+			continue
+		}
+		meta[strings.ToLower(k)] = v
+	}
+	// 第一个raw cell可以使用和markdown相同格式的元数据
+	if len(nb.Cells) > 0 && nb.Cells[0].CellType == "raw" {
+		rawmeta, _, err := page.ReadFrontMatter(strings.NewReader(strings.TrimSpace(string(nb.Cells[0].Source))))
+		if err != nil {
+			return nil, err
+		}
+		if rawmeta != nil {
+			for k, v := range rawmeta {
+				meta[k] = v
+			}
+			nb.Cells = nb.Cells[1:]
+		}
+	}
+	return meta, nil
+}
+
+func (m *ipynb) Read(file string) (page.Meta, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	nb := &notebook{}
+	if err := json.Unmarshal(buf, nb); err != nil {
+		return nil, err
+	}
+	meta, err := readMeta(nb)
+	if err != nil {
+		return nil, err
+	}
+	content, err := m.render(filepath.Dir(file), nb)
+	if err != nil {
+		return nil, err
+	}
+	meta["content"] = content
+	meta["summary"] = m.conf.GetSummary(content)
+	return meta, nil
+}
+
+func New(conf config.Config, theme theme.Theme) page.Reader {
+	return &ipynb{
+		conf:     conf,
+		markdown: markdown.New(conf, theme).(htmlRenderer),
+	}
+}
+
+func init() {
+	page.RegisterWithTheme(".ipynb", New)
+}
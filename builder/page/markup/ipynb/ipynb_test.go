@@ -0,0 +1,85 @@
+package ipynb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/honmaple/snow/builder/page/markup/markdown"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testNotebook = `{
+ "metadata": {
+  "title": "analysis",
+  "kernelspec": {"language": "python", "name": "python3"}
+ },
+ "cells": [
+  {"cell_type": "raw", "metadata": {}, "source": ["---\n", "date: 2023-02-24\n", "tags: [data]\n", "---\n"]},
+  {"cell_type": "markdown", "metadata": {}, "source": "# Hello"},
+  {"cell_type": "code", "metadata": {}, "source": ["print(1)"], "outputs": [
+   {"output_type": "stream", "name": "stdout", "text": ["1\n"]},
+   {"output_type": "display_data", "data": {"image/png": "aGVsbG8=\n", "text/plain": ["<Figure>"]}}
+  ]},
+  {"cell_type": "code", "metadata": {"tags": ["hide-input"]}, "source": "secret()", "outputs": [
+   {"output_type": "execute_result", "data": {"text/plain": "42"}}
+  ]},
+  {"cell_type": "code", "metadata": {"tags": ["remove-cell"]}, "source": "removed()", "outputs": []}
+ ],
+ "nbformat": 4
+}`
+
+func TestRead(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("content_highlight_style", "")
+	conf.Set("ipynb_hide_input_tags", []string{"hide-input"})
+	conf.Set("ipynb_hide_cell_tags", []string{"remove-cell"})
+
+	file := filepath.Join(t.TempDir(), "test.ipynb")
+	assert.Nil(t, os.WriteFile(file, []byte(testNotebook), 0644))
+
+	m := &ipynb{conf: conf, markdown: markdown.New(conf, nil).(htmlRenderer)}
+	meta, err := m.Read(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "analysis", meta.GetString("title"))
+	assert.Equal(t, []string{"data"}, meta.GetSlice("tags"))
+
+	content := meta.GetString("content")
+	assert.Contains(t, content, "<h1>Hello</h1>")
+	assert.Contains(t, content, `<pre><code class="language-python">print(1)</code></pre>`)
+	assert.Contains(t, content, `<pre class="output-stdout">1`)
+	assert.Contains(t, content, `<img src="data:image/png;base64,aGVsbG8="/>`)
+	assert.NotContains(t, content, "&lt;Figure&gt;")
+	assert.NotContains(t, content, "secret()")
+	assert.Contains(t, content, `<pre class="output-result">42</pre>`)
+	assert.NotContains(t, content, "removed()")
+}
+
+func TestReadInclude(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("content_highlight_style", "")
+
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+
+	src := "```go {file=\"main.go\"}\n```\n"
+	for name, c := range map[string]cell{
+		"markdown cell": {CellType: "markdown", Source: source(src)},
+		"markdown output": {CellType: "code", Outputs: []output{
+			{OutputType: "display_data", Data: map[string]source{"text/markdown": source(src)}},
+		}},
+	} {
+		buf, err := json.Marshal(notebook{Cells: []cell{c}})
+		assert.Nil(t, err)
+
+		file := filepath.Join(dir, "test.ipynb")
+		assert.Nil(t, os.WriteFile(file, buf, 0644))
+
+		m := &ipynb{conf: conf, markdown: markdown.New(conf, nil).(htmlRenderer)}
+		meta, err := m.Read(file)
+		assert.Nil(t, err, name)
+		assert.Contains(t, meta.GetString("content"), "package main", name)
+	}
+}
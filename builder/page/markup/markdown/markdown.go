@@ -5,67 +5,45 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme"
 	"github.com/honmaple/snow/builder/theme/template"
 	"github.com/honmaple/snow/config"
 	"github.com/russross/blackfriday/v2"
-	"github.com/spf13/viper"
 )
 
 var (
-	// 兼容hugo
-	MARKDOWN_LINE = regexp.MustCompile(`^[-|\+]{3}\s*$`)
 	MARKDOWN_MORE = regexp.MustCompile(`^\s*(?i:<!--more-->)\s*$`)
 	MARKDOWN_META = regexp.MustCompile(`^([^:]+):(\s+(.*)|$)`)
 )
 
 type markdown struct {
-	conf config.Config
-	opts []blackfriday.Option
+	conf  config.Config
+	hooks map[string]template.Writer
 }
 
 func readMeta(r io.Reader, content *bytes.Buffer, summary *bytes.Buffer) (page.Meta, error) {
+	meta, r, err := page.ReadFrontMatter(r)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		meta = make(page.Meta)
+	}
+
 	var (
 		isMeta    = true
-		isFormat  = true
 		isSummery = true
-		meta      = make(page.Meta)
 		scanner   = bufio.NewScanner(r)
 	)
-
 	for scanner.Scan() {
 		line := scanner.Text()
-		if isFormat && MARKDOWN_LINE.MatchString(line) {
-			var b bytes.Buffer
-			for scanner.Scan() {
-				l := scanner.Text()
-				if strings.TrimSpace(l) == "" || MARKDOWN_LINE.MatchString(l) {
-					break
-				}
-				b.WriteString(l)
-				b.WriteString("\n")
-			}
-			cf := viper.New()
-			if line == "---" {
-				cf.SetConfigType("yaml")
-			} else {
-				cf.SetConfigType("toml")
-			}
-
-			if err := cf.ReadConfig(&b); err != nil {
-				return nil, err
-			}
-			// 不要直接使用meta反序列化数据, 否则子元素map类型也会是page.Meta
-			meta = page.Meta(cf.AllSettings())
-			isFormat = false
-			continue
-		}
-		isFormat = false
-
 		if isMeta {
 			if match := MARKDOWN_META.FindStringSubmatch(line); match != nil {
 				meta.Set(strings.ToLower(match[1]), strings.TrimSpace(match[3]))
@@ -83,43 +61,98 @@ func readMeta(r io.Reader, content *bytes.Buffer, summary *bytes.Buffer) (page.M
 	return meta, nil
 }
 
-func (m *markdown) Read(r io.Reader) (page.Meta, error) {
+func (m *markdown) Read(file string) (page.Meta, error) {
+	filebuf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
 		summary bytes.Buffer
 		content bytes.Buffer
 	)
-	meta, err := readMeta(r, &content, &summary)
+	meta, err := readMeta(bytes.NewReader(filebuf), &content, &summary)
 	if err != nil {
 		return nil, err
 	}
 	buf := content.Bytes()
-	if summary.Len() == 0 {
-		meta["summary"] = m.HTML(buf, true)
+	sbuf := summary.Bytes()
+	dir := filepath.Dir(file)
+
+	// 内容在文件中的起始行, 用于输出shortcode错误所在的行号
+	line := bytes.Count(filebuf, []byte("\n")) - bytes.Count(buf, []byte("\n")) + 1
+	if len(filebuf) > 0 && filebuf[len(filebuf)-1] != '\n' {
+		line++
+	}
+	parser := page.NewShortcodeParser(func(data []byte) (string, error) {
+		return m.render(dir, data, false, meta)
+	})
+	if buf, err = parser.Parse(buf, line); err != nil {
+		return nil, err
+	}
+	if sbuf, err = parser.Parse(sbuf, line); err != nil {
+		return nil, err
+	}
+	if shortcodes := parser.Shortcodes(); len(shortcodes) > 0 {
+		meta["shortcodes"] = shortcodes
+	}
+	if m.conf.GetBool("content_wikilinks") {
+		buf, sbuf = wikilinks(buf), wikilinks(sbuf)
+	}
+	if len(sbuf) == 0 {
+		meta["summary"], err = m.render(dir, buf, true, meta)
 	} else {
-		meta["summary"] = m.HTML(summary.Bytes(), false)
+		meta["summary"], err = m.render(dir, sbuf, false, meta)
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta["content"], err = m.render(dir, buf, false, meta)
+	if err != nil {
+		return nil, err
 	}
-	meta["content"] = m.HTML(buf, false)
 	return meta, nil
 }
 
-func (m *markdown) HTML(data []byte, summary bool) string {
-	d := blackfriday.Run(data, m.opts...)
+func (m *markdown) HTML(data []byte, summary bool) (string, error) {
+	return m.render("", data, summary, nil)
+}
+
+// HTMLWithDir 引用的文件使用相对于dir的路径, 用于其它格式中嵌入的markdown
+func (m *markdown) HTMLWithDir(dir string, data []byte, summary bool) (string, error) {
+	return m.render(dir, data, summary, nil)
+}
+
+// dir为当前文件所在的目录, 引用的文件使用相对于dir的路径
+func (m *markdown) render(dir string, data []byte, summary bool, meta page.Meta) (string, error) {
+	// 每次渲染使用新的renderer, 避免并发读取时共享错误信息
+	r := NewChromaRenderer(m.conf, m.hooks, meta)
+	r.dir = dir
+
+	opts := []blackfriday.Option{blackfriday.WithRenderer(r)}
+	if m.conf.GetBool("content_heading_ids") || m.conf.GetBool("content_wikilinks") {
+		// toc和[[Page#Heading]] 需要标题的id
+		opts = append(opts, blackfriday.WithExtensions(blackfriday.CommonExtensions|blackfriday.AutoHeadingIDs))
+	}
+	d := blackfriday.Run(data, opts...)
+	if err := r.Err(); err != nil {
+		return "", err
+	}
 	if summary {
-		return m.conf.GetSummary(string(d))
+		return m.conf.GetSummary(string(d)), nil
 	}
-	return string(d)
+	return string(d), nil
 }
 
-func New(conf config.Config) page.Reader {
-	return &markdown{conf, []blackfriday.Option{
-		blackfriday.WithRenderer(NewChromaRenderer(conf.GetHighlightStyle())),
-	}}
+func New(conf config.Config, theme theme.Theme) page.Reader {
+	return &markdown{
+		conf:  conf,
+		hooks: page.LookupRenderHooks(theme, "link", "image", "heading", "codeblock", "table"),
+	}
 }
 
 func NewPongo2Filter(conf config.Config) pongo2.FilterFunction {
-	r := &markdown{conf, []blackfriday.Option{
-		blackfriday.WithRenderer(NewChromaRenderer(conf.GetHighlightStyle())),
-	}}
+	r := &markdown{conf: conf}
 	return func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
 		v, ok := in.Interface().(string)
 		if !ok {
@@ -128,11 +161,18 @@ func NewPongo2Filter(conf config.Config) pongo2.FilterFunction {
 				OrigError: errors.New("filter input argument must be of type 'string'"),
 			}
 		}
-		return pongo2.AsValue(r.HTML([]byte(v), false)), nil
+		out, err := r.HTML([]byte(v), false)
+		if err != nil {
+			return nil, &pongo2.Error{
+				Sender:    "filter:markdown",
+				OrigError: err,
+			}
+		}
+		return pongo2.AsValue(out), nil
 	}
 }
 
 func init() {
-	page.Register(".md", New)
+	page.RegisterWithTheme(".md", New)
 	template.RegisterConfigFilter("markdown", NewPongo2Filter)
 }
@@ -2,9 +2,15 @@ package markdown
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/flosch/pongo2/v6"
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme/template"
+	"github.com/honmaple/snow/config"
 	"github.com/honmaple/snow/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -94,3 +100,71 @@ content
 	assertFunc(t, text1)
 	assertFunc(t, text2)
 }
+
+func TestInclude(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(file, []byte("package main\n\n// tag::main[]\nfunc main() {}\n// end::main[]\n"), 0644)
+
+	conf := config.DefaultConfig()
+	conf.Set("content_highlight_style", "")
+
+	m := &markdown{conf: conf}
+	out, err := m.HTML([]byte("```go {file=\""+file+"\" lines=\"1\"}\n```\n"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, "<pre><code class=\"language-go\">package main\n</code></pre>\n", out)
+
+	out, err = m.HTML([]byte("```go file=\""+file+"\" tag=\"main\"\n```\n"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, "<pre><code class=\"language-go\">func main() {}\n</code></pre>\n", out)
+
+	_, err = m.HTML([]byte("```go {file=\""+file+"\" lines=\"3-10\"}\n```\n"), false)
+	assert.NotNil(t, err)
+
+	_, err = m.HTML([]byte("```go {file=\"notfound.go\"}\n```\n"), false)
+	assert.NotNil(t, err)
+
+	// 默认标题没有id, 设置content_heading_ids后生成id
+	out, err = m.HTML([]byte("## Hello World\n"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, "<h2>Hello World</h2>\n", out)
+
+	conf.Set("content_heading_ids", true)
+	out, err = m.HTML([]byte("## Hello World\n"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, "<h2 id=\"hello-world\">Hello World</h2>\n", out)
+	conf.Set("content_heading_ids", false)
+
+	// 相对路径使用当前文件所在的目录
+	page := filepath.Join(filepath.Dir(file), "page.md")
+	os.WriteFile(page, []byte("```go {file=\"main.go\" lines=\"1\"}\n```\n"), 0644)
+	meta, err := m.Read(page)
+	assert.Nil(t, err)
+	assert.Equal(t, "<pre><code class=\"language-go\">package main\n</code></pre>\n", meta.GetString("content"))
+}
+
+type testWriter struct {
+	tpl *pongo2.Template
+}
+
+func (w *testWriter) Name() string                                        { return "" }
+func (w *testWriter) Write(string, map[string]interface{}) error          { return nil }
+func (w *testWriter) Execute(vars map[string]interface{}) (string, error) { return w.tpl.Execute(vars) }
+
+func TestRenderHook(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("content_highlight_style", "")
+
+	m := &markdown{conf: conf, hooks: map[string]template.Writer{
+		"link":      &testWriter{pongo2.Must(pongo2.FromString(`<a href="{{ destination }}" title="{{ page.title }}">{{ text|safe }}</a>`))},
+		"heading":   &testWriter{pongo2.Must(pongo2.FromString(`<h{{ level }} id="{{ anchor }}">{{ text|safe }}</h{{ level }}>`))},
+		"table":     &testWriter{pongo2.Must(pongo2.FromString(`<div class="table">{{ html|safe }}</div>`))},
+		"codeblock": &testWriter{pongo2.Must(pongo2.FromString(`<figure title="{{ attributes.title }}">{{ html|safe }}</figure>`))},
+	}}
+	out, err := m.render("", []byte("## Hello World\n\n[**link**](/a)\n"), false, page.Meta{"title": "aaa"})
+	assert.Nil(t, err)
+	assert.Equal(t, "<h2 id=\"hello-world\">Hello World</h2>\n<p><a href=\"/a\" title=\"aaa\"><strong>link</strong></a></p>\n", out)
+
+	out, err = m.render("", []byte("| a |\n|---|\n| b |\n\n```go {title=\"main.go\"}\npackage main\n```\n"), false, page.Meta{})
+	assert.Nil(t, err)
+	assert.Equal(t, "<div class=\"table\">\n<table><thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n\n<tbody>\n<tr>\n<td>b</td>\n</tr>\n</tbody>\n</table>\n</div><figure title=\"main.go\">\n<pre><code class=\"language-go\">package main\n</code></pre>\n</figure>", out)
+}
@@ -1,57 +1,218 @@
 package markdown
 
 import (
+	"bytes"
 	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
 
-	"github.com/alecthomas/chroma"
-	"github.com/alecthomas/chroma/formatters/html"
 	"github.com/alecthomas/chroma/lexers"
-	"github.com/alecthomas/chroma/styles"
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme/template"
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
 	"github.com/russross/blackfriday/v2"
+	"github.com/shurcooL/sanitized_anchor_name"
+)
+
+var (
+	// ```go {file="main.go" lines="10-42" tag="main"}
+	MARKDOWN_CODE_ATTR = regexp.MustCompile(`(\w+)=("[^"]*"|\S+)`)
 )
 
 type ChromaRenderer struct {
 	html  *blackfriday.HTMLRenderer
+	conf  config.Config
+	meta  page.Meta
+	hooks map[string]template.Writer
 	theme string
+	dir   string
+	err   error
 }
 
-func (r *ChromaRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
-	if r.theme != "" && node.Type == blackfriday.CodeBlock {
-		var lexer chroma.Lexer
-
-		lang := string(node.CodeBlockData.Info)
-		if lang != "" {
-			lexer = lexers.Get(lang)
-		} else {
-			lexer = lexers.Analyse(string(node.Literal))
-		}
+func (r *ChromaRenderer) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
 
-		if lexer == nil {
-			lexer = lexers.Fallback
-		}
+func (r *ChromaRenderer) renderChildren(node *blackfriday.Node) string {
+	var buf bytes.Buffer
+	for child := node.FirstChild; child != nil; child = child.Next {
+		child.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+			return r.RenderNode(&buf, n, entering)
+		})
+	}
+	return buf.String()
+}
 
-		style := styles.Get(r.theme)
-		if style == nil {
-			style = styles.Fallback
+func (r *ChromaRenderer) renderDefault(node *blackfriday.Node, children string) string {
+	var buf bytes.Buffer
+	if node.Type == blackfriday.CodeBlock {
+		r.renderCodeBlock(&buf, node)
+		return buf.String()
+	}
+	r.html.RenderNode(&buf, node, true)
+	buf.WriteString(children)
+	r.html.RenderNode(&buf, node, false)
+	return buf.String()
+}
+
+func (r *ChromaRenderer) renderHook(w io.Writer, node *blackfriday.Node, attrs map[string]interface{}) bool {
+	var (
+		kind string
+		vars = make(map[string]interface{})
+	)
+	switch node.Type {
+	case blackfriday.Link:
+		// 脚注
+		if node.NoteID != 0 {
+			return false
+		}
+		kind = "link"
+		vars["destination"] = string(node.LinkData.Destination)
+		vars["title"] = string(node.LinkData.Title)
+	case blackfriday.Image:
+		kind = "image"
+		vars["destination"] = string(node.LinkData.Destination)
+		vars["title"] = string(node.LinkData.Title)
+	case blackfriday.Heading:
+		kind = "heading"
+		anchor := node.HeadingData.HeadingID
+		if anchor == "" {
+			var b strings.Builder
+			node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+				if n.Type == blackfriday.Text || n.Type == blackfriday.Code {
+					b.Write(n.Literal)
+				}
+				return blackfriday.GoToNext
+			})
+			anchor = sanitized_anchor_name.Create(b.String())
 		}
+		vars["level"] = node.HeadingData.Level
+		vars["anchor"] = anchor
+	case blackfriday.CodeBlock:
+		kind = "codeblock"
+		vars["lang"] = string(node.CodeBlockData.Info)
+		vars["code"] = string(node.Literal)
+	case blackfriday.Table:
+		kind = "table"
+	default:
+		return false
+	}
+	tpl, ok := r.hooks[kind]
+	if !ok {
+		return false
+	}
+	children := ""
+	if node.Type != blackfriday.CodeBlock {
+		children = r.renderChildren(node)
+		vars["text"] = children
+	}
+	vars["html"] = r.renderDefault(node, children)
+	vars["attributes"] = attrs
+	vars["page"] = r.meta
+
+	out, err := tpl.Execute(vars)
+	if err != nil {
+		r.setErr(err)
+		return true
+	}
+	io.WriteString(w, out)
+	return true
+}
+
+// 返回代码块的属性, 同时处理file, lines和tag
+func (r *ChromaRenderer) include(node *blackfriday.Node) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+
+	info := strings.TrimSpace(string(node.CodeBlockData.Info))
+	if info == "" {
+		return params, nil
+	}
+	lang, attrs := info, ""
+	if i := strings.IndexAny(info, " {"); i >= 0 {
+		lang, attrs = info[:i], strings.Trim(strings.TrimSpace(info[i:]), "{}")
+	}
+	node.CodeBlockData.Info = []byte(lang)
 
-		iterator, _ := lexer.Tokenise(nil, string(node.Literal))
+	for _, match := range MARKDOWN_CODE_ATTR.FindAllStringSubmatch(attrs, -1) {
+		params[match[1]] = strings.Trim(match[2], `"`)
+	}
+	file, _ := params["file"].(string)
+	if file == "" {
+		return params, nil
+	}
+	if r.dir != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(r.dir, file)
+	}
+	lines, _ := params["lines"].(string)
+	tag, _ := params["tag"].(string)
+	content, err := utils.ReadFileLines(file, lines, tag)
+	if err != nil {
+		return nil, err
+	}
+	r.conf.Watch(file)
 
-		err := html.New().Format(w, style, iterator)
+	if lang == "" {
+		if lexer := lexers.Match(file); lexer != nil {
+			node.CodeBlockData.Info = []byte(lexer.Config().Name)
+		}
+	}
+	node.Literal = []byte(content)
+	return params, nil
+}
+
+func (r *ChromaRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+	attrs := make(map[string]interface{})
+	if node.Type == blackfriday.CodeBlock {
+		params, err := r.include(node)
 		if err != nil {
-			panic(err)
+			r.setErr(err)
+			return blackfriday.Terminate
 		}
+		attrs = params
+	}
+	if entering && r.renderHook(w, node, attrs) {
+		if r.err != nil {
+			return blackfriday.Terminate
+		}
+		return blackfriday.SkipChildren
+	}
+	if node.Type == blackfriday.CodeBlock {
+		r.renderCodeBlock(w, node)
 		return blackfriday.GoToNext
 	}
 	return r.html.RenderNode(w, node, entering)
 }
 
+func (r *ChromaRenderer) renderCodeBlock(w io.Writer, node *blackfriday.Node) {
+	if r.theme != "" {
+		out, err := utils.Highlight(string(node.Literal), string(node.CodeBlockData.Info), r.theme, nil)
+		if err != nil {
+			r.setErr(err)
+			return
+		}
+		io.WriteString(w, out)
+		return
+	}
+	r.html.RenderNode(w, node, true)
+}
+
 func (r *ChromaRenderer) RenderHeader(w io.Writer, ast *blackfriday.Node) {}
 func (r *ChromaRenderer) RenderFooter(w io.Writer, ast *blackfriday.Node) {}
 
-func NewChromaRenderer(theme string) *ChromaRenderer {
+func (r *ChromaRenderer) Err() error {
+	return r.err
+}
+
+func NewChromaRenderer(conf config.Config, hooks map[string]template.Writer, meta page.Meta) *ChromaRenderer {
 	return &ChromaRenderer{
 		html:  blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{}),
-		theme: theme,
+		conf:  conf,
+		meta:  meta,
+		hooks: hooks,
+		theme: conf.GetHighlightStyle(),
 	}
 }
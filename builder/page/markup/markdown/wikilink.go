@@ -0,0 +1,120 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/shurcooL/sanitized_anchor_name"
+)
+
+var (
+	// [[Page]], [[Page|label]], [[Page#Heading]], ![[image.png]]
+	MARKDOWN_WIKILINK = regexp.MustCompile(`(!?)\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]`)
+	MARKDOWN_FENCE    = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+
+	wikiImageExts = map[string]bool{
+		".png":  true,
+		".jpg":  true,
+		".jpeg": true,
+		".gif":  true,
+		".svg":  true,
+		".webp": true,
+	}
+)
+
+func wikilink(match []string) string {
+	embed, target, label := match[1] == "!", strings.TrimSpace(match[2]), strings.TrimSpace(match[3])
+
+	name, anchor := target, ""
+	if i := strings.Index(target, "#"); i >= 0 {
+		name, anchor = strings.TrimSpace(target[:i]), sanitized_anchor_name.Create(target[i+1:])
+	}
+	if embed {
+		if wikiImageExts[strings.ToLower(filepath.Ext(name))] {
+			if label == "" {
+				label = filepath.Base(name)
+			}
+			return fmt.Sprintf("![%s](%s)", label, (&url.URL{Path: name}).String())
+		}
+		return fmt.Sprintf(`<div class="wikilink-embed" data-wikilink="%s"></div>`, url.PathEscape(name))
+	}
+	if label == "" {
+		label = target
+	}
+	link := "wiki:" + url.PathEscape(name)
+	if name == "" {
+		// [[#Heading]]
+		link = ""
+	}
+	if anchor != "" {
+		link = link + "#" + anchor
+	}
+	return fmt.Sprintf("[%s](%s)", label, link)
+}
+
+func replaceWikilinks(line string) string {
+	var (
+		b     strings.Builder
+		start = 0
+	)
+	// 忽略行内代码
+	for {
+		i := strings.Index(line[start:], "`")
+		if i < 0 {
+			b.WriteString(MARKDOWN_WIKILINK.ReplaceAllStringFunc(line[start:], func(s string) string {
+				return wikilink(MARKDOWN_WIKILINK.FindStringSubmatch(s))
+			}))
+			break
+		}
+		i = start + i
+		b.WriteString(MARKDOWN_WIKILINK.ReplaceAllStringFunc(line[start:i], func(s string) string {
+			return wikilink(MARKDOWN_WIKILINK.FindStringSubmatch(s))
+		}))
+
+		n := i
+		for n < len(line) && line[n] == '`' {
+			n++
+		}
+		end := strings.Index(line[n:], line[i:n])
+		if end < 0 {
+			b.WriteString(line[i:])
+			break
+		}
+		end = n + end + n - i
+		b.WriteString(line[i:end])
+		start = end
+	}
+	return b.String()
+}
+
+// 转换wikilink为普通的markdown链接, 最终的链接地址在所有页面读取完成后替换
+func wikilinks(content []byte) []byte {
+	if !bytes.Contains(content, []byte("[[")) {
+		return content
+	}
+	var (
+		b     bytes.Buffer
+		fence string
+	)
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if match := MARKDOWN_FENCE.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+			} else if fence == match[1] {
+				fence = ""
+			}
+			b.WriteString(line)
+			continue
+		}
+		if fence != "" {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(replaceWikilinks(line))
+	}
+	return b.Bytes()
+}
@@ -4,16 +4,23 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/honmaple/org-golang"
 	"github.com/honmaple/org-golang/render"
 	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme"
 	"github.com/honmaple/snow/builder/theme/template"
 	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
 )
 
 var (
@@ -21,22 +28,288 @@ var (
 	ORGMODE_KEYWORD    = regexp.MustCompile(`^#\+([^:]+):(\s+(.*)|$)`)
 	ORGMODE_PROPERTIES = regexp.MustCompile(`^(?i::PROPERTIES:)$`)
 	ORGMODE_META       = regexp.MustCompile(`^:([^:]+):(\s+(.*)|$)`)
+	ORGMODE_MACRO      = regexp.MustCompile(`\{\{\{([^\(\}]+)(?:\((.*?)\))?\}\}\}`)
+	ORGMODE_BLOCK      = regexp.MustCompile(`^\s*#\+(?i:(begin|end)_(src|example))\b`)
+	ORGMODE_MAX_DEPTH  = 10
 )
 
 type orgmode struct {
-	conf config.Config
+	conf  config.Config
+	hooks map[string]template.Writer
 }
 
-func readMeta(r io.Reader, content *bytes.Buffer, summary *bytes.Buffer) (page.Meta, error) {
+type metaReader struct {
+	meta   page.Meta
+	files  []string
+	macros map[string]string
+}
+
+// 按照空格分割参数, 支持双引号
+func splitArgs(s string) []string {
+	var (
+		args   = make([]string, 0)
+		quoted = false
+		b      strings.Builder
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if b.Len() > 0 {
+				args = append(args, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		args = append(args, b.String())
+	}
+	return args
+}
+
+func (m *metaReader) resolve(dir, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+func (m *metaReader) setKeyword(dir, key, value string, depth int) error {
+	switch strings.ToUpper(key) {
+	case "PROPERTY":
+		s := strings.SplitN(value, " ", 2)
+		k := strings.ToLower(s[0])
+		v := ""
+		if len(s) > 1 {
+			v = strings.TrimSpace(s[1])
+		}
+		m.meta.Set(k, v)
+	case "SETUPFILE":
+		return m.readSetupFile(m.resolve(dir, strings.Trim(value, `"`)), depth+1)
+	case "MACRO":
+		s := strings.SplitN(value, " ", 2)
+		if len(s) == 2 {
+			m.macros[s[0]] = strings.TrimSpace(s[1])
+		} else {
+			m.macros[s[0]] = ""
+		}
+	case "FILETAGS":
+		tags := strings.FieldsFunc(value, func(r rune) bool { return r == ':' || r == ' ' })
+		if len(tags) > 0 {
+			m.meta.Set("tags", "["+strings.Join(tags, ",")+"]")
+		}
+	default:
+		m.meta.Set(strings.ToLower(key), value)
+	}
+	return nil
+}
+
+// 读取 #+SETUPFILE 中的关键字和宏, 不包括内容
+func (m *metaReader) readSetupFile(file string, depth int) error {
+	if depth > ORGMODE_MAX_DEPTH {
+		return fmt.Errorf("%s: too many nested setup files", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m.files = append(m.files, file)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		match := ORGMODE_KEYWORD.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		if err := m.setKeyword(filepath.Dir(file), match[1], strings.TrimSpace(match[3]), depth); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// #+INCLUDE: "file.org" :lines "5-10" :minlevel 2
+// #+INCLUDE: "main.go" src go
+func (m *metaReader) readInclude(dir, value string, depth int) (string, error) {
+	args := splitArgs(value)
+	if len(args) == 0 {
+		return "", nil
+	}
+	file := m.resolve(dir, args[0])
+	if depth > ORGMODE_MAX_DEPTH {
+		return "", fmt.Errorf("%s: too many nested includes", file)
+	}
+
+	var (
+		block    []string
+		lines    string
+		minlevel int
+	)
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case ":lines":
+			if i+1 < len(args) {
+				lines = args[i+1]
+				i++
+			}
+		case ":minlevel":
+			if i+1 < len(args) {
+				minlevel, _ = strconv.Atoi(args[i+1])
+				i++
+			}
+		default:
+			if !strings.HasPrefix(args[i], ":") {
+				block = append(block, args[i])
+			}
+		}
+	}
+
+	text, err := utils.ReadFileLines(file, includeLines(lines), "")
+	if err != nil {
+		return "", err
+	}
+	m.files = append(m.files, file)
+
+	if len(block) > 0 {
+		return fmt.Sprintf("#+begin_%s\n%s#+end_%s\n", strings.Join(block, " "), text, block[0]), nil
+	}
+
+	var (
+		b       strings.Builder
+		inBlock = false
+	)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		inBlock = isBlock(line, inBlock)
+		if match := ORGMODE_KEYWORD.FindStringSubmatch(line); !inBlock && match != nil && strings.ToUpper(match[1]) == "INCLUDE" {
+			s, err := m.readInclude(filepath.Dir(file), strings.TrimSpace(match[3]), depth+1)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	text = b.String()
+	if minlevel > 0 {
+		text = shiftHeadings(text, minlevel)
+	}
+	return text, nil
+}
+
+// org中:lines "5-10"不包括第10行, 转换为ReadFileLines使用的"5-9"
+func includeLines(lines string) string {
+	s := strings.SplitN(lines, "-", 2)
+	if len(s) != 2 || strings.TrimSpace(s[1]) == "" {
+		return lines
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(s[1]))
+	if err != nil {
+		return lines
+	}
+	return s[0] + "-" + strconv.Itoa(end-1)
+}
+
+// 返回当前行是否在src或者example代码块中, 代码块中的INCLUDE和宏保持原样
+func isBlock(line string, inBlock bool) bool {
+	match := ORGMODE_BLOCK.FindStringSubmatch(line)
+	if match == nil {
+		return inBlock
+	}
+	return strings.ToLower(match[1]) == "begin"
+}
+
+// 调整标题的级别, 使最小的标题级别为minlevel
+func shiftHeadings(text string, minlevel int) string {
+	lines := strings.Split(text, "\n")
+	level := 0
+	for _, line := range lines {
+		if match := ORGMODE_HEADING.FindStringSubmatch(line); match != nil {
+			if level == 0 || len(match[1]) < level {
+				level = len(match[1])
+			}
+		}
+	}
+	if level == 0 || level >= minlevel {
+		return text
+	}
+	stars := strings.Repeat("*", minlevel-level)
+	for i, line := range lines {
+		if ORGMODE_HEADING.MatchString(line) {
+			lines[i] = stars + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// {{{name(arg1,arg2)}}}
+func (m *metaReader) expandMacros(line string) string {
+	if !strings.Contains(line, "{{{") {
+		return line
+	}
+	return ORGMODE_MACRO.ReplaceAllStringFunc(line, func(s string) string {
+		match := ORGMODE_MACRO.FindStringSubmatch(s)
+		name := strings.ToLower(strings.TrimSpace(match[1]))
+
+		value, ok := m.macros[name]
+		if !ok {
+			switch name {
+			case "title", "author", "email", "date":
+				return m.meta.GetString(name)
+			case "keyword":
+				return m.meta.GetString(strings.ToLower(strings.TrimSpace(match[2])))
+			}
+			return s
+		}
+		args := make([]string, 0)
+		if match[2] != "" {
+			args = strings.Split(strings.ReplaceAll(match[2], `\,`, "\x00"), ",")
+		}
+		for i := len(args); i > 0; i-- {
+			arg := strings.ReplaceAll(strings.TrimSpace(args[i-1]), "\x00", ",")
+			value = strings.ReplaceAll(value, "$"+strconv.Itoa(i), arg)
+		}
+		return value
+	})
+}
+
+func (m *metaReader) expandText(text string) string {
+	var (
+		b       strings.Builder
+		inBlock = false
+	)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if inBlock = isBlock(line, inBlock); inBlock {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(m.expandMacros(line))
+	}
+	return b.String()
+}
+
+func (m *metaReader) read(r io.Reader, dir string, content *bytes.Buffer, summary *bytes.Buffer) error {
 	var (
-		meta      = make(page.Meta)
 		scanner   = bufio.NewScanner(r)
 		isMeta    = true
 		isFormat  = true
 		isSummary = true
+		inBlock   = false
 	)
 	for scanner.Scan() {
 		line := scanner.Text()
+		if inBlock = isBlock(line, inBlock); inBlock {
+			isMeta = false
+			content.WriteString(line)
+			content.WriteString("\n")
+			continue
+		}
 		if isFormat && ORGMODE_PROPERTIES.MatchString(line) {
 			for scanner.Scan() {
 				l := scanner.Text()
@@ -47,75 +320,173 @@ func readMeta(r io.Reader, content *bytes.Buffer, summary *bytes.Buffer) (page.M
 				if match == nil || match[1] == "END" {
 					break
 				}
-				meta.Set(match[1], match[2])
+				m.meta.Set(match[1], match[2])
 			}
 			isFormat = false
 			continue
 		}
-		if isMeta {
-			if match := ORGMODE_KEYWORD.FindStringSubmatch(line); match != nil {
-				if match[1] == "PROPERTY" {
-					s := strings.SplitN(match[3], " ", 2)
-					k := strings.ToLower(s[0])
-					v := ""
-					if len(s) > 1 {
-						v = strings.TrimSpace(s[1])
-					}
-					meta.Set(k, v)
-				} else {
-					meta.Set(strings.ToLower(match[1]), strings.TrimSpace(match[3]))
+		if match := ORGMODE_KEYWORD.FindStringSubmatch(line); match != nil {
+			key, value := match[1], strings.TrimSpace(match[3])
+			if strings.ToUpper(key) == "INCLUDE" {
+				s, err := m.readInclude(dir, value, 1)
+				if err != nil {
+					return err
+				}
+				content.WriteString(m.expandText(s))
+				isMeta = false
+				continue
+			}
+			if isMeta {
+				if err := m.setKeyword(dir, key, value, 0); err != nil {
+					return err
 				}
 				continue
 			}
 		}
 		isMeta = false
+		// 文件级别的属性只能位于第一个标题之前
+		if isFormat && strings.HasPrefix(line, "*") {
+			isFormat = false
+		}
 		if isSummary && ORGMODE_MORE.MatchString(line) {
 			summary.WriteString(content.String())
 			isSummary = false
 		}
-		content.WriteString(line)
+		content.WriteString(m.expandMacros(line))
 		content.WriteString("\n")
 	}
-	return meta, nil
+	return scanner.Err()
+}
+
+func newMetaReader() *metaReader {
+	return &metaReader{
+		meta:   make(page.Meta),
+		files:  make([]string, 0),
+		macros: make(map[string]string),
+	}
 }
 
-func (m *orgmode) Read(r io.Reader) (page.Meta, error) {
+func readMeta(r io.Reader, content *bytes.Buffer, summary *bytes.Buffer) (page.Meta, error) {
+	m := newMetaReader()
+	if err := m.read(r, "", content, summary); err != nil {
+		return nil, err
+	}
+	return m.meta, nil
+}
+
+func (m *orgmode) Read(file string) (page.Meta, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
 	var (
 		content bytes.Buffer
 		summary bytes.Buffer
+		reader  = newMetaReader()
+		dir     = filepath.Dir(file)
 	)
-	meta, err := readMeta(r, &content, &summary)
-	if err != nil {
+	if err := reader.read(f, dir, &content, &summary); err != nil {
 		return nil, err
 	}
+	// 被引用的文件修改后需要重新构建
+	for _, file := range reader.files {
+		m.conf.Watch(file)
+	}
+
+	meta := reader.meta
 	buf := content.Bytes()
-	if summary.Len() == 0 {
-		meta["summary"] = m.HTML(buf, false, true)
+	sbuf := summary.Bytes()
+
+	// 使用了INCLUDE时行号可能不准确
+	line := 1
+	if filebuf, err := os.ReadFile(file); err == nil {
+		line = bytes.Count(filebuf, []byte("\n")) - bytes.Count(buf, []byte("\n")) + 1
+		if len(filebuf) > 0 && filebuf[len(filebuf)-1] != '\n' {
+			line++
+		}
+		if line < 1 {
+			line = 1
+		}
+	}
+	parser := page.NewShortcodeParser(func(data []byte) (string, error) {
+		return m.render(dir, data, false, false, meta)
+	})
+	if buf, err = parser.Parse(buf, line); err != nil {
+		return nil, err
+	}
+	if sbuf, err = parser.Parse(sbuf, line); err != nil {
+		return nil, err
+	}
+	if shortcodes := parser.Shortcodes(); len(shortcodes) > 0 {
+		meta["shortcodes"] = shortcodes
+	}
+	if _, ok := meta["date"]; !ok {
+		if date := planningDate(firstPlanning(content.String())); date != "" {
+			meta["date"] = date
+		}
+	}
+	if len(sbuf) == 0 {
+		meta["summary"], err = m.render(dir, buf, false, true, meta)
 	} else {
-		meta["summary"] = m.HTML(summary.Bytes(), false, false)
+		meta["summary"], err = m.render(dir, sbuf, false, false, meta)
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta["content"], err = m.render(dir, buf, true, false, meta)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(buf, []byte(":EXPORT_FILE_NAME:")) {
+		var (
+			once     sync.Once
+			subtrees []page.Meta
+			suberr   error
+		)
+		// 只有开启subtree_export时才渲染每个子树
+		meta["subtrees"] = page.Subtrees(func() ([]page.Meta, error) {
+			once.Do(func() {
+				subtrees, suberr = m.readSubtrees(dir, meta, buf)
+			})
+			return subtrees, suberr
+		})
 	}
-	meta["content"] = m.HTML(buf, true, false)
 	return meta, nil
 }
 
-func (m *orgmode) HTML(data []byte, showToc bool, summary bool) string {
+func (m *orgmode) HTML(data []byte, showToc bool, summary bool) (string, error) {
+	return m.render("", data, showToc, summary, nil)
+}
+
+// dir为当前文件所在的目录, 引用的文件使用相对于dir的路径
+func (m *orgmode) render(dir string, data []byte, showToc bool, summary bool, meta page.Meta) (string, error) {
+	r := &renderer{conf: m.conf, meta: meta, hooks: m.hooks, dir: dir}
 	rd := render.HTML{
 		Toc:            showToc,
 		Document:       org.New(bytes.NewBuffer(data)),
-		RenderNodeFunc: m.renderNode,
+		RenderNodeFunc: r.renderNode,
+	}
+	out := rd.String()
+	if r.err != nil {
+		return "", r.err
 	}
 	if summary {
-		return m.conf.GetSummary(rd.String())
+		return m.conf.GetSummary(out), nil
 	}
-	return rd.String()
+	return out, nil
 }
 
-func New(conf config.Config) page.Reader {
-	return &orgmode{conf}
+func New(conf config.Config, theme theme.Theme) page.Reader {
+	return &orgmode{
+		conf:  conf,
+		hooks: page.LookupRenderHooks(theme, "link", "image", "heading", "codeblock", "table"),
+	}
 }
 
 func NewPongo2Filter(conf config.Config) pongo2.FilterFunction {
-	r := &orgmode{conf}
+	r := &orgmode{conf: conf}
 	return func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
 		v, ok := in.Interface().(string)
 		if !ok {
@@ -124,11 +495,18 @@ func NewPongo2Filter(conf config.Config) pongo2.FilterFunction {
 				OrigError: errors.New("filter input argument must be of type 'string'"),
 			}
 		}
-		return pongo2.AsValue(r.HTML([]byte(v), false, false)), nil
+		out, err := r.HTML([]byte(v), false, false)
+		if err != nil {
+			return nil, &pongo2.Error{
+				Sender:    "filter:org",
+				OrigError: err,
+			}
+		}
+		return pongo2.AsValue(out), nil
 	}
 }
 
 func init() {
-	page.Register(".org", New)
+	page.RegisterWithTheme(".org", New)
 	template.RegisterConfigFilter("org", NewPongo2Filter)
 }
@@ -2,9 +2,12 @@ package orgmode
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/honmaple/snow/config"
 	"github.com/honmaple/snow/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -71,3 +74,108 @@ content
 	assertFunc(t, text)
 	assertFunc(t, text1)
 }
+
+func TestSubtree(t *testing.T) {
+	text := `#+TITLE: blog
+#+AUTHOR: snow
+
+* not exported
+* DONE First post :emacs:@dev:
+CLOSED: [2023-04-05 Wed 10:20]
+:PROPERTIES:
+:EXPORT_FILE_NAME: first-post
+:END:
+hello
+** sub
+world
+* TODO [#A] Second post
+:PROPERTIES:
+:EXPORT_FILE_NAME: second
+:EXPORT_DATE: 2023-05-01
+:SLUG: post-2
+:END:
+draft
+* other
+`
+	var (
+		content bytes.Buffer
+		summary bytes.Buffer
+	)
+	meta, _ := readMeta(strings.NewReader(text), &content, &summary)
+	assert.Equal(t, "blog", meta.GetString("title"))
+
+	subtrees := readSubtrees(meta, content.Bytes())
+	assert.Equal(t, 2, len(subtrees))
+	assert.Equal(t, "hello\n** sub\nworld\n", subtrees[0].content.String())
+	assert.Equal(t, "draft\n", subtrees[1].content.String())
+
+	m1 := subtrees[0].meta(meta)
+	assert.Equal(t, "First post", m1.GetString("title"))
+	assert.Equal(t, "first-post", m1.GetString("slug"))
+	assert.Equal(t, "snow", m1.GetString("author"))
+	assert.Equal(t, "2023-04-05 10:20", m1.GetString("date"))
+	assert.Equal(t, []string{"emacs"}, m1.GetSlice("tags"))
+	assert.Equal(t, []string{"dev"}, m1.GetSlice("categories"))
+	assert.Equal(t, false, m1.GetBool("draft"))
+
+	m2 := subtrees[1].meta(meta)
+	assert.Equal(t, "Second post", m2.GetString("title"))
+	assert.Equal(t, "post-2", m2.GetString("slug"))
+	assert.Equal(t, "2023-05-01", m2.GetString("date"))
+	assert.Equal(t, true, m2.GetBool("draft"))
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "common.setup"), []byte("#+AUTHOR: snow\n#+MACRO: greet Hello, $1!\n#+FILETAGS: :shared:\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "part.org"), []byte("* part\nbody\n** sub\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n// end\n"), 0644)
+
+	text := `#+TITLE: aaa
+#+SETUPFILE: common.setup
+#+FILETAGS: :org:emacs:
+
+{{{greet(world)}}} by {{{author}}}
+#+INCLUDE: "part.org" :minlevel 2
+#+INCLUDE: "part.org" :lines "2-3" src text
+#+INCLUDE: "main.go" :lines "1-4" src go
+#+begin_src org
+#+INCLUDE: "part.org"
+{{{author}}}
+#+end_src
+`
+	var (
+		content bytes.Buffer
+		summary bytes.Buffer
+		reader  = newMetaReader()
+	)
+	assert.Nil(t, reader.read(strings.NewReader(text), dir, &content, &summary))
+	assert.Equal(t, "snow", reader.meta.GetString("author"))
+	assert.Equal(t, []string{"shared", "org", "emacs"}, reader.meta.GetSlice("tags"))
+	assert.Equal(t, "\nHello, world! by snow\n** part\nbody\n*** sub\n#+begin_src text\nbody\n#+end_src\n#+begin_src go\npackage main\n\nfunc main() {}\n#+end_src\n#+begin_src org\n#+INCLUDE: \"part.org\"\n{{{author}}}\n#+end_src\n", content.String())
+	assert.Equal(t, []string{filepath.Join(dir, "common.setup"), filepath.Join(dir, "part.org"), filepath.Join(dir, "part.org"), filepath.Join(dir, "main.go")}, reader.files)
+
+	assert.NotNil(t, reader.read(strings.NewReader(`#+INCLUDE: "unknown.org"`), dir, &content, &summary))
+
+	assert.Equal(t, "2022-03-04 09:30", planningDate("SCHEDULED: <2022-03-01 Tue> CLOSED: [2022-03-04 Fri 09:30]"))
+	assert.Equal(t, "2022-03-01", planningDate("SCHEDULED: <2022-03-01 Tue>"))
+
+	// 只使用文件级别或者第一个标题后的planning
+	assert.Equal(t, "CLOSED: [2022-03-04 Fri]", firstPlanning("#+TITLE: aaa\n* DONE aaa\nCLOSED: [2022-03-04 Fri]\n** TODO bbb\nSCHEDULED: <2022-05-01 Sun>\n"))
+	assert.Equal(t, "", firstPlanning("* aaa\nbody\n** TODO bbb\nCLOSED: [2022-05-01 Sun]\n"))
+}
+
+func TestHighlight(t *testing.T) {
+	conf := config.DefaultConfig()
+	m := &orgmode{conf: conf}
+
+	text := "#+begin_src go\npackage main\n#+end_src\n"
+	for _, style := range []string{"", "monokai"} {
+		conf.Set("content_highlight_style", style)
+
+		out, err := m.HTML([]byte(text), false, false)
+		assert.Nil(t, err)
+		assert.Contains(t, out, `<pre style="`, style)
+		assert.NotContains(t, out, `class="language-go"`, style)
+	}
+}
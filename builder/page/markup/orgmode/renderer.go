@@ -1,51 +1,194 @@
 package orgmode
 
 import (
+	"fmt"
+	"net/url"
+	"path/filepath"
 	"strings"
 
-	"github.com/alecthomas/chroma"
-	"github.com/alecthomas/chroma/formatters/html"
 	"github.com/alecthomas/chroma/lexers"
 	"github.com/alecthomas/chroma/styles"
 	"github.com/honmaple/org-golang/parser"
 	"github.com/honmaple/org-golang/render"
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme/template"
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
 )
 
-func (m *orgmode) highlightCodeBlock(source, lang string) string {
-	theme := m.conf.GetHighlightStyle()
+type renderer struct {
+	conf  config.Config
+	meta  page.Meta
+	hooks map[string]template.Writer
+	dir   string
+	err   error
+}
+
+func (m *renderer) setErr(err error) {
+	if m.err == nil {
+		m.err = err
+	}
+}
 
-	var w strings.Builder
-	var lexer chroma.Lexer
+func (m *renderer) renderHook(kind string, vars map[string]interface{}) (string, bool) {
+	tpl, ok := m.hooks[kind]
+	if !ok {
+		return "", false
+	}
+	if _, ok := vars["attributes"]; !ok {
+		vars["attributes"] = make(map[string]interface{})
+	}
+	vars["page"] = m.meta
+
+	out, err := tpl.Execute(vars)
+	if err != nil {
+		m.setErr(err)
+		return "", true
+	}
+	return out, true
+}
 
-	if lang == "" || lang == "example" {
-		lexer = lexers.Analyse(source)
+func (m *renderer) renderLink(r render.Renderer, node *parser.InlineLink) (string, bool) {
+	dest := node.URL
+	if node.Protocol != "" && node.Protocol != "file" {
+		dest = node.Protocol + "://" + node.URL
 	} else {
-		lexer = lexers.Get(lang)
+		dest = strings.TrimPrefix(dest, "file:")
+	}
+	vars := map[string]interface{}{
+		"destination": dest,
+		"title":       "",
 	}
-	if lexer == nil {
-		lexer = lexers.Fallback
+	switch node.Type() {
+	case parser.ImageLink:
+		text := dest
+		if u, err := url.Parse(dest); err == nil {
+			text = filepath.Base(u.Path)
+		}
+		vars["text"] = text
+		vars["html"] = r.RenderNode(node, true)
+		return m.renderHook("image", vars)
+	case parser.RegularLink:
+		vars["text"] = node.Desc
+		vars["html"] = r.RenderNode(node, true)
+		return m.renderHook("link", vars)
 	}
+	return "", false
+}
 
-	style := styles.Get(theme)
-	if style == nil {
-		style = styles.Fallback
+func (m *renderer) renderHeading(r render.Renderer, node *parser.Heading) (string, bool) {
+	if _, ok := m.hooks["heading"]; !ok {
+		return "", false
 	}
+	text := r.RenderNodes(node.Title, "")
+	out, ok := m.renderHook("heading", map[string]interface{}{
+		"level":  node.Stars,
+		"anchor": node.Id(),
+		"text":   text,
+		"html":   fmt.Sprintf("<h%[1]d id=\"%[2]s\">%[3]s</h%[1]d>", node.Stars, node.Id(), text),
+	})
+	if len(node.Children) > 0 {
+		out = out + "\n" + r.RenderNodes(node.Children, "\n")
+	}
+	return out, ok
+}
 
-	it, _ := lexer.Tokenise(nil, source)
-	_ = html.New().Format(&w, style, it)
-	return w.String()
+func (m *renderer) highlightCodeBlock(source, lang string) string {
+	if lang == "example" {
+		lang = ""
+	}
+	// 没有设置高亮样式时使用chroma的默认样式
+	style := m.conf.GetHighlightStyle()
+	if style == "" {
+		style = styles.Fallback.Name
+	}
+	out, err := utils.Highlight(source, lang, style, nil)
+	if err != nil {
+		m.setErr(err)
+	}
+	return out
+}
+
+// #+begin_src go :file main.go :lines 10-42 :tag main
+func (m *renderer) include(params []string) (string, string, error) {
+	var file, lines, tag string
+	for i := 0; i < len(params)-1; i++ {
+		switch strings.ToLower(params[i]) {
+		case ":file":
+			file = params[i+1]
+		case ":lines":
+			lines = strings.Trim(params[i+1], `"`)
+		case ":tag":
+			tag = params[i+1]
+		}
+	}
+	if file == "" {
+		return "", "", nil
+	}
+	if m.dir != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(m.dir, file)
+	}
+	content, err := utils.ReadFileLines(file, lines, tag)
+	if err != nil {
+		return "", "", err
+	}
+	m.conf.Watch(file)
+	return file, content, nil
 }
 
-func (m *orgmode) renderNode(r render.Renderer, n parser.Node) string {
+func (m *renderer) renderNode(r render.Renderer, n parser.Node) string {
 	switch node := n.(type) {
 	case *parser.Block:
 		if node.Type == "SRC" || node.Type == "EXAMPLE" {
 			lang := ""
-			if len(node.Parameters) > 0 {
+			if len(node.Parameters) > 0 && !strings.HasPrefix(node.Parameters[0], ":") {
 				lang = node.Parameters[0]
 			}
-			text := render.DedentString(r.RenderNodes(node.Children, "\n"))
-			return m.highlightCodeBlock(text, lang)
+			file, text, err := m.include(node.Parameters)
+			if err != nil {
+				m.setErr(err)
+				return ""
+			}
+			if file == "" {
+				text = render.DedentString(r.RenderNodes(node.Children, "\n"))
+			} else if lang == "" {
+				if lexer := lexers.Match(file); lexer != nil {
+					lang = lexer.Config().Name
+				}
+			}
+			// :file main.go :lines 10-42 -> {"file": "main.go", "lines": "10-42"}
+			attrs := make(map[string]interface{})
+			for i := 0; i < len(node.Parameters)-1; i++ {
+				if k, v := node.Parameters[i], node.Parameters[i+1]; strings.HasPrefix(k, ":") && !strings.HasPrefix(v, ":") {
+					attrs[strings.ToLower(k[1:])] = strings.Trim(v, `"`)
+				}
+			}
+			out := m.highlightCodeBlock(text, lang)
+			if hook, ok := m.renderHook("codeblock", map[string]interface{}{
+				"lang":       lang,
+				"code":       text,
+				"html":       out,
+				"attributes": attrs,
+			}); ok {
+				return hook
+			}
+			return out
+		}
+	case *parser.InlineLink:
+		if out, ok := m.renderLink(r, node); ok {
+			return out
+		}
+	case *parser.Heading:
+		if out, ok := m.renderHeading(r, node); ok {
+			return out
+		}
+	case *parser.Table:
+		if _, ok := m.hooks["table"]; ok {
+			out, _ := m.renderHook("table", map[string]interface{}{
+				"text": r.RenderNodes(node.Children, "\n"),
+				"html": r.RenderNode(node, true),
+			})
+			return out
 		}
 	}
 	return r.RenderNode(n, true)
@@ -0,0 +1,217 @@
+package orgmode
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/utils"
+)
+
+var (
+	ORGMODE_HEADING  = regexp.MustCompile(`^(\*+)\s+(.*?)\s*$`)
+	ORGMODE_TAGS     = regexp.MustCompile(`^(.*?)\s+:([^\s]+):$`)
+	ORGMODE_PRIORITY = regexp.MustCompile(`^\[#[A-Za-z]\]\s*`)
+	ORGMODE_PLANNING = regexp.MustCompile(`^\s*(?:(?:CLOSED|SCHEDULED|DEADLINE):\s*[<\[][^>\]]+[>\]]\s*)+$`)
+	ORGMODE_END      = regexp.MustCompile(`^\s*(?i::END:)\s*$`)
+	ORGMODE_DATE     = regexp.MustCompile(`(CLOSED|SCHEDULED):\s*[<\[](\d{4}-\d{2}-\d{2})(?:\s+[^\s\]>\d]+)?(?:\s+(\d{2}:\d{2}))?`)
+)
+
+type subtree struct {
+	stars    int
+	keyword  string
+	title    string
+	tags     []string
+	props    [][2]string
+	planning string
+	content  bytes.Buffer
+}
+
+func parseHeading(line string, todo []string) (*subtree, bool) {
+	match := ORGMODE_HEADING.FindStringSubmatch(line)
+	if match == nil {
+		return nil, false
+	}
+	s := &subtree{stars: len(match[1])}
+
+	title := match[2]
+	if v := strings.SplitN(title, " ", 2); len(v) == 2 && utils.CheckInList(todo, v[0]) {
+		s.keyword, title = v[0], v[1]
+	}
+	title = ORGMODE_PRIORITY.ReplaceAllString(title, "")
+	if m := ORGMODE_TAGS.FindStringSubmatch(title); m != nil {
+		title = m[1]
+		s.tags = strings.FieldsFunc(m[2], func(r rune) bool { return r == ':' })
+	}
+	s.title = strings.TrimSpace(title)
+	return s, true
+}
+
+// 优先使用CLOSED时间, 其次是SCHEDULED时间
+func planningDate(text string) string {
+	date := ""
+	for _, match := range ORGMODE_DATE.FindAllStringSubmatch(text, -1) {
+		value := strings.TrimSpace(match[2] + " " + match[3])
+		if match[1] == "CLOSED" {
+			return value
+		}
+		if date == "" {
+			date = value
+		}
+	}
+	return date
+}
+
+// 文件级别的planning, 或者第一个标题后的planning, 不包括其它标题中的时间
+func firstPlanning(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if ORGMODE_HEADING.MatchString(line) {
+			if i+1 < len(lines) && ORGMODE_PLANNING.MatchString(lines[i+1]) {
+				return lines[i+1]
+			}
+			return ""
+		}
+		if ORGMODE_PLANNING.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
+
+func (s *subtree) prop(key string) string {
+	for _, prop := range s.props {
+		if strings.ToUpper(prop[0]) == key {
+			return prop[1]
+		}
+	}
+	return ""
+}
+
+// 将带有 :EXPORT_FILE_NAME: 属性的标题拆分为单独的页面
+func readSubtrees(meta page.Meta, content []byte) []*subtree {
+	todo := strings.FieldsFunc(meta.GetString("todo"), func(r rune) bool { return r == ' ' || r == '|' })
+	if len(todo) == 0 {
+		todo = []string{"TODO", "DONE", "CANCELED"}
+	}
+
+	var (
+		current  *subtree
+		subtrees = make([]*subtree, 0)
+		lines    = strings.Split(string(content), "\n")
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if s, ok := parseHeading(line, todo); ok {
+			j := i + 1
+			if j < len(lines) && ORGMODE_PLANNING.MatchString(lines[j]) {
+				s.planning = strings.TrimSpace(lines[j])
+				j++
+			}
+			if j < len(lines) && ORGMODE_PROPERTIES.MatchString(strings.TrimSpace(lines[j])) {
+				for j = j + 1; j < len(lines); j++ {
+					if ORGMODE_END.MatchString(lines[j]) {
+						j++
+						break
+					}
+					if match := ORGMODE_META.FindStringSubmatch(strings.TrimSpace(lines[j])); match != nil {
+						s.props = append(s.props, [2]string{match[1], strings.TrimSpace(match[3])})
+					}
+				}
+			}
+			if s.prop("EXPORT_FILE_NAME") != "" {
+				current = s
+				subtrees = append(subtrees, current)
+				i = j - 1
+				continue
+			}
+			if current != nil && s.stars <= current.stars {
+				current = nil
+			}
+		}
+		if current != nil {
+			current.content.WriteString(line)
+			current.content.WriteString("\n")
+		}
+	}
+	return subtrees
+}
+
+func (s *subtree) meta(filemeta page.Meta) page.Meta {
+	meta := make(page.Meta)
+	for k, v := range filemeta {
+		switch k {
+		case "title", "slug", "content", "summary", "subtrees":
+			continue
+		}
+		meta[k] = v
+	}
+	meta["title"] = s.title
+	for _, prop := range s.props {
+		key := strings.ToLower(prop[0])
+		if strings.HasPrefix(key, "export_") && key != "export_file_name" {
+			key = key[7:]
+		}
+		meta.Set(key, prop[1])
+	}
+	if meta.GetString("slug") == "" {
+		meta["slug"] = utils.FileBaseName(meta.GetString("export_file_name"))
+	}
+
+	tags := make([]string, 0)
+	categories := make([]string, 0)
+	for _, tag := range s.tags {
+		// ox-hugo: @tag 表示分类
+		if strings.HasPrefix(tag, "@") {
+			categories = append(categories, tag[1:])
+		} else {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 0 {
+		meta.Set("tags", "["+strings.Join(tags, ",")+"]")
+	}
+	if len(categories) > 0 {
+		meta.Set("categories", "["+strings.Join(categories, ",")+"]")
+	}
+	// 和ox-hugo一致, 未设置日期时使用CLOSED或者SCHEDULED时间
+	if s.prop("EXPORT_DATE") == "" && s.prop("DATE") == "" {
+		if date := planningDate(s.planning); date != "" {
+			meta["date"] = date
+		}
+	}
+	switch s.keyword {
+	case "":
+	case "TODO":
+		meta["draft"] = true
+	default:
+		meta["draft"] = false
+	}
+	return meta
+}
+
+func (m *orgmode) readSubtrees(dir string, meta page.Meta, content []byte) ([]page.Meta, error) {
+	subtrees := readSubtrees(meta, content)
+	if len(subtrees) == 0 {
+		return nil, nil
+	}
+	metas := make([]page.Meta, len(subtrees))
+	for i, s := range subtrees {
+		var (
+			err     error
+			buf     = s.content.Bytes()
+			submeta = s.meta(meta)
+		)
+		submeta["summary"], err = m.render(dir, buf, false, true, submeta)
+		if err != nil {
+			return nil, err
+		}
+		submeta["content"], err = m.render(dir, buf, true, false, submeta)
+		if err != nil {
+			return nil, err
+		}
+		metas[i] = submeta
+	}
+	return metas, nil
+}
@@ -1,7 +1,9 @@
 package page
 
 import (
+	"fmt"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -116,13 +118,28 @@ type (
 		Next          *Page
 		PrevInSection *Page
 		NextInSection *Page
+		Backlinks     Pages
 
 		Formats Formats
 		Section *Section
+		History Commits
+
+		key string
 	}
 	Pages []*Page
+
+	// 从一个文件拆分的多个页面, 只有开启subtree_export时才会调用
+	Subtrees func() ([]Meta, error)
 )
 
+// Key 页面的唯一标识, 从一个文件拆分的页面为 file#CUSTOM_ID
+func (page *Page) Key() string {
+	if page.key == "" {
+		return page.File
+	}
+	return page.key
+}
+
 func FilterExpr(filter string) func(*Page) bool {
 	if filter == "" {
 		return func(*Page) bool {
@@ -161,6 +178,9 @@ func (page *Page) realPath(pathstr string) string {
 	if filename == "index" && !page.Section.isRoot() {
 		filename = filepath.Base(filepath.Dir(page.File))
 	}
+	if name := page.Meta.GetString("export_file_name"); name != "" {
+		filename = utils.FileBaseName(name)
+	}
 	vars := map[string]string{
 		"{date:%Y}":      page.Date.Format("2006"),
 		"{date:%m}":      page.Date.Format("01"),
@@ -332,18 +352,56 @@ func (pages Pages) Paginator(number int, path string, paginatePath string) []*pa
 	return Paginator(list, number, path, paginatePath)
 }
 
-func (b *Builder) insertPage(file string) *Page {
-	section := b.ctx.findSection(filepath.Dir(file))
+func (b *Builder) insertPage(file string) {
+	dir := filepath.Dir(file)
+	// content/posts/bundle/index.md 属于 posts, content/index.md 仍然属于根目录
+	if strings.HasPrefix(filepath.Base(file), "index.") && dir != filepath.Clean(b.conf.ContentDir) {
+		dir = filepath.Dir(dir)
+	}
+	section := b.ctx.findSection(dir)
 	if section == nil {
-		return nil
+		return
 	}
 
 	filemeta, err := b.readFile(file)
 	if err != nil {
-		return nil
+		b.addError(err)
+		return
+	}
+
+	// 一个文件拆分为多个页面
+	if subtrees, ok := filemeta["subtrees"].(Subtrees); ok {
+		export := section.Meta.GetBool("subtree_export")
+		if v, ok := filemeta["subtree_export"]; ok {
+			export = cast.ToBool(v)
+		}
+		if export {
+			metas, err := subtrees()
+			if err != nil {
+				b.addError(fmt.Errorf("%s: %s", file, err.Error()))
+				return
+			}
+			if len(metas) > 0 {
+				for i, submeta := range metas {
+					id := submeta.GetString("custom_id")
+					if id == "" {
+						id = utils.FileBaseName(submeta.GetString("export_file_name"))
+					}
+					if id == "" {
+						id = strconv.Itoa(i + 1)
+					}
+					b.insertPageMeta(section, file, file+"#"+id, submeta)
+				}
+				return
+			}
+		}
 	}
+	b.insertPageMeta(section, file, "", filemeta)
+}
 
-	meta := section.Meta.clone()
+// 页面使用的section默认配置
+func (sec *Section) pageMeta() Meta {
+	meta := sec.Meta.clone()
 	meta["path"] = meta["page_path"]
 	meta["template"] = meta["page_template"]
 	meta["formats"] = meta["page_formats"]
@@ -351,7 +409,14 @@ func (b *Builder) insertPage(file string) *Page {
 	delete(meta, "title")
 	delete(meta, "content")
 	delete(meta, "summary")
+	return meta
+}
+
+// key为空时使用file作为页面的唯一标识
+func (b *Builder) insertPageMeta(section *Section, file, key string, filemeta Meta) *Page {
+	meta := section.pageMeta()
 	meta.load(filemeta)
+	delete(meta, "subtrees")
 
 	lang := b.findLang(file, meta)
 	if lang != b.conf.Site.Language {
@@ -364,43 +429,55 @@ func (b *Builder) insertPage(file string) *Page {
 		File:    file,
 		Date:    time.Now(),
 		Section: section,
+		key:     key,
+	}
+	b.insertFileMeta(file, meta)
+
+	if err := b.checkSchema(section, meta); err != nil {
+		b.addError(fmt.Errorf("%s: %s", file, err.Error()))
+		return nil
 	}
 	for k, v := range meta {
 		if v == "" {
 			continue
 		}
+		// 数字等类型也转换成字符串, 列表中包含map时保持原样
 		if vs, ok := v.([]interface{}); ok {
-			res := make([]string, len(vs))
-			for i, vv := range vs {
-				res[i] = vv.(string)
+			res := make([]string, 0, len(vs))
+			for _, vv := range vs {
+				s, err := cast.ToStringE(vv)
+				if err != nil {
+					break
+				}
+				res = append(res, s)
+			}
+			if len(res) == len(vs) {
+				v = res
 			}
-			v = res
 		}
 		switch strings.ToLower(k) {
 		case "slug":
-			page.Slug = v.(string)
+			page.Slug = cast.ToString(v)
 		case "title":
-			page.Title = v.(string)
+			page.Title = cast.ToString(v)
 		case "date":
-			if t, ok := v.(time.Time); ok {
-				page.Date = t
-			} else if t, err := utils.ParseTime(v.(string)); err == nil {
+			if t, err := b.conf.ParseTime(v); err == nil {
 				page.Date = t
+				v = t
 			}
 		case "modified":
-			if t, ok := v.(time.Time); ok {
-				page.Modified = t
-			} else if t, err := utils.ParseTime(v.(string)); err == nil {
+			if t, err := b.conf.ParseTime(v); err == nil {
 				page.Modified = t
+				v = t
 			}
 		case "url", "save_as":
-			page.Path = v.(string)
+			page.Path = cast.ToString(v)
 		case "aliases":
-			page.Aliases = v.([]string)
+			page.Aliases = cast.ToStringSlice(v)
 		case "summary":
-			page.Summary = v.(string)
+			page.Summary = cast.ToString(v)
 		case "content":
-			page.Content = v.(string)
+			page.Content = cast.ToString(v)
 		}
 		meta[k] = v
 	}
@@ -411,6 +488,12 @@ func (b *Builder) insertPage(file string) *Page {
 		}
 		page.Title = filename
 	}
+	if history, ok := b.findHistory(file); ok {
+		page.History = history
+		if page.Modified.IsZero() {
+			page.Modified = history[0].Date.In(b.conf.GetTimezone())
+		}
+	}
 	if page.Modified.IsZero() {
 		page.Modified = page.Date
 	}
@@ -424,17 +507,52 @@ func (b *Builder) insertPage(file string) *Page {
 	page.Permalink = b.conf.GetURL(page.Path)
 	page.Formats = b.formats(page.Meta, nil)
 
-	page = b.hooks.Page(page)
-	if page == nil {
-		return nil
+	if shortcodes, ok := meta["shortcodes"].(Shortcodes); ok {
+		delete(meta, "shortcodes")
+		page.Content = b.renderShortcodes(page, page.Content, shortcodes, make(map[string]int))
+		page.Summary = b.renderShortcodes(page, page.Summary, shortcodes, make(map[string]int))
 	}
 
-	b.ctx.insertPage(page)
-
-	b.insertTaxonomies(page)
+	// 替换链接后再执行插件
+	b.pageMu.Lock()
+	b.pending = append(b.pending, page)
+	b.pageMu.Unlock()
 	return page
 }
 
+// 使用filename_pattern中的命名分组(比如date, slug)设置元数据中未设置的值
+func (b *Builder) insertFileMeta(file string, meta Meta) {
+	pattern := meta.GetString("filename_pattern")
+	if pattern == "" {
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		b.conf.Log.Warnf("%s: filename_pattern %s", file, err.Error())
+		return
+	}
+	filename := utils.FileBaseName(file)
+	if ext := filepath.Ext(filename); ext != "" && b.conf.IsValidLanguage(ext[1:]) {
+		filename = filename[:len(filename)-len(ext)]
+	}
+	if filename == "index" {
+		filename = filepath.Base(filepath.Dir(file))
+	}
+	match := re.FindStringSubmatch(filename)
+	if match == nil {
+		return
+	}
+	for i, name := range re.SubexpNames() {
+		if name == "" || match[i] == "" {
+			continue
+		}
+		if v, ok := meta[name]; ok && v != nil && v != "" {
+			continue
+		}
+		meta[name] = match[i]
+	}
+}
+
 func (b *Builder) writePage(page *Page) {
 	if !page.isSection() {
 		ctx := map[string]interface{}{
@@ -443,6 +561,10 @@ func (b *Builder) writePage(page *Page) {
 			"current_path": page.Path,
 			"current_lang": page.Lang,
 		}
+		// 先写入页面引用的文件, 写入页面时可以读取图片大小等
+		for _, asset := range page.Assets {
+			b.writeAsset(asset, page.assetPath(asset))
+		}
 		if tpl := b.theme.LookupTemplate(page.Meta.GetString("template")); tpl != nil {
 			b.write(tpl, page.Path, ctx)
 		}
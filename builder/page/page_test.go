@@ -1,8 +1,19 @@
 package page
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -51,3 +62,451 @@ func TestMeta(t *testing.T) {
 		"b": 12,
 	}, m.Get("a"))
 }
+
+func TestResolveLinks(t *testing.T) {
+	ctx := newContext(config.DefaultConfig())
+	ctx.conf.ContentDir = "content"
+
+	section := &Section{File: "content/posts"}
+	foo := &Page{File: "content/posts/2023/foo.md", Path: "/posts/foo.html", Meta: Meta{"id": "uuid"}, Section: section}
+	bar := &Page{File: "content/posts/bar.org", Path: "/posts/bar.html", Meta: Meta{}, Section: section}
+	sub1 := &Page{File: "content/posts/notes.org", key: "content/posts/notes.org#one", Path: "/posts/one.html", Meta: Meta{}, Section: section}
+	sub2 := &Page{File: "content/posts/notes.org", key: "content/posts/notes.org#two", Path: "/posts/two.html", Meta: Meta{}, Section: section}
+
+	r := &linkResolver{
+		ctx: ctx,
+		ids: map[string]*Page{"uuid": foo},
+		pages: map[string]*Page{
+			foo.File:                  foo,
+			bar.File:                  bar,
+			sub1.Key():                sub1,
+			sub2.Key():                sub2,
+			"content/posts/notes.org": sub1,
+		},
+		exts:  map[string]bool{".md": true, ".org": true},
+		links: func(page *Page) string { return page.Path },
+		wikis: []map[string]Pages{
+			{"foo": Pages{foo}},
+		},
+		warned: make(map[string]bool),
+	}
+	assert.Equal(t,
+		`<a href="/posts/foo.html#sec">a</a><a href="/posts/bar.html">b</a><a href="/posts/foo.html">c</a><a href="http://a.com/b.md">d</a>`,
+		r.resolve(bar, `<a href="2023/foo.md#sec">a</a><a href="@/posts/bar.org">b</a><a href="id:uuid">c</a><a href="http://a.com/b.md">d</a>`, false),
+	)
+	assert.Equal(t, `<a href="file:missing.org">e</a>`, r.resolve(bar, `<a href="file:missing.org">e</a>`, false))
+	assert.Equal(t, `<a href="/posts/foo.html#heading">f</a>`, r.resolve(bar, `<a href="wiki:Foo#heading">f</a>`, false))
+	assert.Equal(t, Pages{bar}, foo.Backlinks)
+
+	assert.Equal(t,
+		`<a href="/posts/two.html">g</a><a href="/posts/one.html">h</a><a href="/posts/one.html#x">i</a>`,
+		r.resolve(bar, `<a href="file:notes.org::#two">g</a><a href="notes.org">h</a><a href="notes.org#x">i</a>`, false),
+	)
+
+	// 只嵌入插件处理后仍然存在的页面
+	ctx.insertPage(foo)
+	content := `<div data-wikilink="foo"></div>`
+	assert.Equal(t, content, r.resolve(bar, content, false))
+	foo.Content = "<p>foo</p>"
+	assert.Equal(t, `<div data-wikilink="foo"><p>foo</p></div>`, r.resolve(bar, content, true))
+
+	// content_link_strict
+	errs := make([]string, 0)
+	r.addError = func(err error) {
+		errs = append(errs, err.Error())
+	}
+	r.resolve(bar, `<a href="missing.md">j</a><a href="wiki:Missing">k</a>`, false)
+	assert.Equal(t, []string{
+		"content/posts/bar.org: unresolved link missing.md",
+		"content/posts/bar.org: dangling wikilink [[Missing]]",
+	}, errs)
+}
+
+func TestReadFrontMatter(t *testing.T) {
+	text := `---
+title: aaa
+description: |
+  line1
+
+  line2
+---
+content
+`
+	meta, r, err := ReadFrontMatter(strings.NewReader(text))
+	assert.Nil(t, err)
+	assert.Equal(t, "aaa", meta.GetString("title"))
+	assert.Equal(t, "line1\n\nline2\n", meta.GetString("description"))
+	content, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "content\n", string(content))
+
+	meta, r, err = ReadFrontMatter(strings.NewReader("{\n  \"title\": \"bbb\",\n  \"tags\": [\"a\", \"b\"]\n}\ncontent"))
+	assert.Nil(t, err)
+	assert.Equal(t, "bbb", meta.GetString("title"))
+	assert.Equal(t, []string{"a", "b"}, meta.GetSlice("tags"))
+	content, _ = ioutil.ReadAll(r)
+	assert.Equal(t, "content", string(content))
+
+	meta, r, err = ReadFrontMatter(strings.NewReader("{\"title\": \"ccc\", \"tags\": [\"a\"]}\ncontent"))
+	assert.Nil(t, err)
+	assert.Equal(t, "ccc", meta.GetString("title"))
+	content, _ = ioutil.ReadAll(r)
+	assert.Equal(t, "content", string(content))
+
+	meta, r, err = ReadFrontMatter(strings.NewReader("{{< toc >}}\ncontent"))
+	assert.Nil(t, err)
+	assert.Nil(t, meta)
+	content, _ = ioutil.ReadAll(r)
+	assert.Equal(t, "{{< toc >}}\ncontent", string(content))
+
+	meta, r, err = ReadFrontMatter(strings.NewReader("content"))
+	assert.Nil(t, err)
+	assert.Nil(t, meta)
+	content, _ = ioutil.ReadAll(r)
+	assert.Equal(t, "content", string(content))
+
+	for text, line := range map[string]int{
+		"---\ntitle: aaa\ntags: a: b\n---\n":          3,
+		"+++\ntitle = \"aaa\"\ndate = \n+++\n":        3,
+		"{\n  \"title\": \"aaa\",\n  \"tags\": \n}\n": 4,
+		"---\ntitle: aaa\n":                           1,
+		"{\"title\": \"aaa\"\ncontent":                2,
+	} {
+		_, _, err := ReadFrontMatter(strings.NewReader(text))
+		e, ok := err.(*FrontMatterError)
+		assert.True(t, ok)
+		if ok {
+			assert.Equal(t, line, e.Line, text)
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	b := &Builder{conf: config.DefaultConfig()}
+	section := &Section{Meta: Meta{
+		"schema": map[string]interface{}{
+			"title":  map[string]interface{}{"type": "string", "required": true},
+			"tags":   "list",
+			"weight": "int",
+			"date":   "date",
+			"status": map[string]interface{}{"type": "string", "enum": []interface{}{"draft", "published"}, "default": "draft"},
+		},
+	}}
+
+	meta := Meta{"title": 2023, "tags": []interface{}{"a", 1}, "weight": "3", "date": "2023-02-24"}
+	assert.Nil(t, b.checkSchema(section, meta))
+
+	date, _ := utils.ParseTime("2023-02-24")
+	assert.Equal(t, Meta{
+		"title":  "2023",
+		"tags":   []string{"a", "1"},
+		"weight": 3,
+		"date":   date,
+		"status": "draft",
+	}, meta)
+
+	assert.EqualError(t, b.checkSchema(section, Meta{}), "schema: key title is required")
+	assert.EqualError(t, b.checkSchema(section, Meta{"title": "a", "weight": "x"}), `schema: key weight must be int, got "x"`)
+	assert.EqualError(t, b.checkSchema(section, Meta{"title": "a", "status": "done"}), "schema: key status value done must be one of draft, published")
+	assert.NotNil(t, b.checkSchema(section, Meta{"title": "a", "date": "24/02/2023"}))
+}
+
+func TestParseTime(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("timezone", "Asia/Shanghai")
+	conf.Set("date_formats", []string{"02/01/2006"})
+
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	tests := map[string]time.Time{
+		"2023-01-02":                time.Date(2023, 1, 2, 0, 0, 0, 0, loc),
+		"2023/01/02 10:00":          time.Date(2023, 1, 2, 10, 0, 0, 0, loc),
+		"02/01/2023":                time.Date(2023, 1, 2, 0, 0, 0, 0, loc),
+		"<2023-01-02 Mon 10:00>":    time.Date(2023, 1, 2, 10, 0, 0, 0, loc),
+		"[2023-01-02 Mon]":          time.Date(2023, 1, 2, 0, 0, 0, 0, loc),
+		"<2023-01-02 Mon 9:00 +1w>": time.Date(2023, 1, 2, 9, 0, 0, 0, loc),
+		"2023-01-02T10:00:00+09:00": time.Date(2023, 1, 2, 9, 0, 0, 0, loc),
+	}
+	for value, expected := range tests {
+		date, err := conf.ParseTime(value)
+		assert.Nil(t, err, value)
+		assert.True(t, expected.Equal(date), value)
+	}
+	// yaml中没有时区的时间使用配置的时区, 明确指定的时区保持不变
+	meta, _, err := ReadFrontMatter(strings.NewReader("---\ndate: 2023-01-02 10:00:00\nmodified: 2023-01-02T10:00:00Z\n---\n"))
+	assert.Nil(t, err)
+	date, err := conf.ParseTime(meta["date"])
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2023, 1, 2, 10, 0, 0, 0, loc).Equal(date))
+	date, err = conf.ParseTime(meta["modified"])
+	assert.Nil(t, err)
+	assert.True(t, time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC).Equal(date))
+
+	date, err = conf.ParseTime(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), date)
+
+	_, err = conf.ParseTime("2023.01.02")
+	assert.NotNil(t, err)
+}
+
+func TestInsertFileMeta(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Set("languages.zh.title", "zh")
+	b := &Builder{conf: conf}
+
+	pattern := `^(?P<date>\d{4}-\d{2}-\d{2})-(?P<slug>.+)$`
+
+	meta := Meta{"filename_pattern": pattern}
+	b.insertFileMeta("content/posts/2023-04-01-my-post.zh.md", meta)
+	assert.Equal(t, "2023-04-01", meta["date"])
+	assert.Equal(t, "my-post", meta["slug"])
+
+	meta = Meta{"filename_pattern": pattern, "slug": "custom"}
+	b.insertFileMeta("content/posts/2023-04-01-my-post/index.md", meta)
+	assert.Equal(t, "2023-04-01", meta["date"])
+	assert.Equal(t, "custom", meta["slug"])
+
+	meta = Meta{"filename_pattern": pattern}
+	b.insertFileMeta("content/posts/my-post.md", meta)
+	assert.Nil(t, meta["date"])
+}
+
+func TestReadAdapterRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "talks.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("talks:\n  items:\n    - title: Go\n    - title: Org\n"), 0644))
+
+	records, err := readAdapterRecords(file, "talks.items")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"title": "Go"}, {"title": "Org"}}, records)
+
+	_, err = readAdapterRecords(file, "talks")
+	assert.EqualError(t, err, "records must be a list")
+
+	file = filepath.Join(dir, "products.csv")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("name,tags\nWidget,\"a, b\"\n"), 0644))
+
+	records, err = readAdapterRecords(file, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"name": "Widget", "tags": "a, b"}}, records)
+}
+
+func TestShortcodeParser(t *testing.T) {
+	parser := NewShortcodeParser(func(data []byte) (string, error) {
+		return "<em>" + strings.TrimSpace(string(data)) + "</em>", nil
+	})
+	src := `a {{< youtube id123 autoplay=true width=1.5 title="A \"quoted\" title" >}}
+{{< note "info" >}}
+{{% box %}}body{{% /box %}}
+{{< /note >}}
+{{</* raw x */>}}`
+
+	buf, err := parser.Parse([]byte(src), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "a SNOWSHORTCODE000000X\nSNOWSHORTCODE000002X\n{{< raw x >}}", string(buf))
+
+	shortcodes := parser.Shortcodes()
+	assert.Equal(t, 3, len(shortcodes))
+	assert.Equal(t, &Shortcode{
+		Name:   "youtube",
+		Args:   []interface{}{"id123"},
+		Params: map[string]interface{}{"autoplay": true, "width": 1.5, "title": `A "quoted" title`},
+		Line:   1,
+	}, shortcodes[0])
+	assert.Equal(t, "<em>body</em>", shortcodes[1].Body)
+	assert.Equal(t, 3, shortcodes[1].Line)
+	assert.Equal(t, "\nSNOWSHORTCODE000001X\n", shortcodes[2].Body)
+	assert.Equal(t, "info", shortcodes[2].Get(0))
+
+	_, err = NewShortcodeParser(nil).Parse([]byte("line1\nline2 {{< /note >}}"), 3)
+	assert.EqualError(t, err, "shortcode line 4: unexpected closing shortcode note")
+
+	_, err = NewShortcodeParser(nil).Parse([]byte("line1\n{{< note title=\"a >}}"), 1)
+	assert.EqualError(t, err, "shortcode line 2: unterminated quoted string")
+}
+
+type testHook struct {
+	Hooks
+	err error
+}
+
+func (h testHook) PageE(page *Page) (*Page, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	page.Title = page.Title + "!"
+	return page, nil
+}
+
+func TestHooks(t *testing.T) {
+	hooks := Hooks{testHook{}, testHook{}}
+	page, err := hooks.page(&Page{Title: "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, "a!!", page.Title)
+
+	// 未实现PageE的插件仍然使用Page
+	hooks = Hooks{Hooks{}, testHook{err: errors.New("invalid page")}}
+	page, err = hooks.page(&Page{Title: "a"})
+	assert.Nil(t, page)
+	assert.EqualError(t, err, "invalid page")
+}
+
+type testReader struct{}
+
+func (testReader) Read(file string) (Meta, error) {
+	return Meta{"content": file}, nil
+}
+
+type testStreamReader struct{}
+
+func (testStreamReader) Read(r io.Reader) (Meta, error) {
+	buf, err := ioutil.ReadAll(r)
+	return Meta{"content": string(buf)}, err
+}
+
+func TestRegister(t *testing.T) {
+	Register(".test", func(config.Config) Reader { return testReader{} })
+	defer delete(_readers, ".test")
+
+	meta, err := _readers[".test"](config.DefaultConfig(), nil).Read("a.test")
+	assert.Nil(t, err)
+	assert.Equal(t, "a.test", meta["content"])
+}
+
+func TestRegisterStream(t *testing.T) {
+	RegisterStream(".stream", func(config.Config) StreamReader { return testStreamReader{} })
+	defer delete(_readers, ".stream")
+
+	file := filepath.Join(t.TempDir(), "a.stream")
+	assert.Nil(t, ioutil.WriteFile(file, []byte("hello"), 0644))
+
+	meta, err := _readers[".stream"](config.DefaultConfig(), nil).Read(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", meta["content"])
+}
+
+func TestInsertPage(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"index.md", "posts/a.md", "posts/bundle/index.md", "posts/bundle/pic.png"} {
+		file = filepath.Join(dir, "content", file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+	}
+
+	conf := config.DefaultConfig()
+	conf.Load("")
+	conf.Init()
+	conf.ContentDir = filepath.Join(dir, "content")
+
+	b := NewBuilder(conf, nil, nil)
+	b.readers = map[string]Reader{".md": testReader{}}
+	assert.Nil(t, b.Read(context.Background()))
+
+	// content/posts/bundle/index.md 属于 posts, bundle不再作为section
+	posts := b.ctx.findSection(filepath.Join(conf.ContentDir, "posts"))
+	assert.NotNil(t, posts)
+	assert.Nil(t, b.ctx.findSection(filepath.Join(conf.ContentDir, "posts", "bundle")))
+	assert.Equal(t, 2, len(posts.Pages))
+
+	bundle := b.ctx.findPage(filepath.Join(conf.ContentDir, "posts", "bundle", "index.md"))
+	assert.NotNil(t, bundle)
+	assert.Equal(t, posts, bundle.Section)
+	assert.Equal(t, "bundle", bundle.Title)
+
+	// content/index.md 属于根目录
+	root := b.ctx.findSection(conf.ContentDir)
+	index := b.ctx.findPage(filepath.Join(conf.ContentDir, "index.md"))
+	assert.NotNil(t, index)
+	assert.Equal(t, root, index.Section)
+}
+
+func TestAssetPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/posts/a/index.html": "/posts/a/cover.png",
+		"/posts/a/":           "/posts/a/cover.png",
+		"/posts/a.html":       "/posts/a/cover.png",
+		"/posts/b.html":       "/posts/b/cover.png",
+		"/":                   "/cover.png",
+	} {
+		page := &Page{Path: path}
+		assert.Equal(t, expected, page.assetPath("content/posts/a/cover.png"))
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read(file string) (Meta, error) {
+	return nil, errors.New("line 3: include file not found")
+}
+
+func TestReadError(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "content", "posts", "a.md")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+
+	conf := config.DefaultConfig()
+	conf.Load("")
+	conf.Init()
+	conf.ContentDir = filepath.Join(dir, "content")
+
+	b := NewBuilder(conf, nil, nil)
+	b.readers = map[string]Reader{".md": errReader{}}
+	err := b.Read(context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), file)
+	assert.Contains(t, err.Error(), "line 3: include file not found")
+}
+
+func TestRenderShortcodesError(t *testing.T) {
+	b := &Builder{shortcodes: map[string]ShortcodeFunc{
+		"fail": func(*Page, *Shortcode, string) (string, error) {
+			return "", errors.New("missing src")
+		},
+	}}
+	out := b.renderShortcodes(&Page{File: "a.md"}, shortcodePlaceholder(0), Shortcodes{{Name: "fail", Line: 3}}, make(map[string]int))
+	assert.Equal(t, "", out)
+	assert.EqualError(t, b.error(), "a.md: shortcode line 3: missing src")
+}
+
+func TestReadGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=snow", "-c", "user.email=snow@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		assert.Nil(t, err, string(out))
+	}
+	write := func(file, content string) {
+		file = filepath.Join(dir, file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0644))
+	}
+	git("init", "-q")
+	write("content/posts/a.md", "# Hello\n\nthis is a long enough paragraph for rename detection\n")
+	write("README.md", "readme")
+	git("add", "-A")
+	git("commit", "-q", "-m", "add a")
+	git("mv", "content/posts/a.md", "content/posts/b.md")
+	git("commit", "-q", "-m", "rename a to b")
+	write("content/posts/b.md", "# Hello\n\nthis is a long enough paragraph for rename detection\nmore\n")
+	git("commit", "-q", "-am", "update b")
+
+	// 内容目录使用绝对路径, 当前目录不在git仓库中
+	history, err := readGitHistory(filepath.Join(dir, "content"))
+	assert.Nil(t, err)
+
+	commits := history[realPath(filepath.Join(dir, "content", "posts", "b.md"))]
+	subjects := make([]string, len(commits))
+	for i, commit := range commits {
+		subjects[i] = commit.Subject
+	}
+	assert.Equal(t, []string{"update b", "rename a to b", "add a"}, subjects)
+	assert.Equal(t, []string{"snow"}, commits.Authors())
+	assert.Nil(t, history[realPath(filepath.Join(dir, "README.md"))])
+
+	_, err = readGitHistory(t.TempDir())
+	assert.NotNil(t, err)
+}
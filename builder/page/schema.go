@@ -0,0 +1,137 @@
+package page
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+)
+
+type schemaField struct {
+	Type     string
+	Required bool
+	Enum     []string
+	Default  interface{}
+}
+
+func (f *schemaField) coerce(conf config.Config, v interface{}) (interface{}, error) {
+	switch f.Type {
+	case "", "any":
+		return v, nil
+	case "string":
+		return cast.ToStringE(v)
+	case "int":
+		return cast.ToIntE(v)
+	case "bool":
+		return cast.ToBoolE(v)
+	case "date":
+		return conf.ParseTime(v)
+	case "list":
+		switch value := v.(type) {
+		case string:
+			return utils.SplitTrim(value, ","), nil
+		case []string:
+			return value, nil
+		case []interface{}:
+			res := make([]string, len(value))
+			for i, vv := range value {
+				s, err := cast.ToStringE(vv)
+				if err != nil {
+					return nil, err
+				}
+				res[i] = s
+			}
+			return res, nil
+		}
+		s, err := cast.ToStringE(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	return v, nil
+}
+
+func (f *schemaField) check(v interface{}) error {
+	if len(f.Enum) == 0 {
+		return nil
+	}
+	values, ok := v.([]string)
+	if !ok {
+		values = []string{cast.ToString(v)}
+	}
+	for _, value := range values {
+		if !utils.CheckInList(f.Enum, value) {
+			return fmt.Errorf("value %s must be one of %s", value, strings.Join(f.Enum, ", "))
+		}
+	}
+	return nil
+}
+
+// schema:
+//
+//	title: {type: string, required: true}
+//	tags: list
+//	status: {type: string, enum: [draft, published], default: draft}
+func parseSchema(schema map[string]interface{}) map[string]*schemaField {
+	fields := make(map[string]*schemaField)
+	for k, v := range schema {
+		field := &schemaField{}
+		if typ, ok := v.(string); ok {
+			field.Type = typ
+		} else {
+			m := cast.ToStringMap(v)
+			field.Type = cast.ToString(m["type"])
+			field.Required = cast.ToBool(m["required"])
+			field.Enum = cast.ToStringSlice(m["enum"])
+			field.Default = m["default"]
+		}
+		fields[strings.ToLower(k)] = field
+	}
+	return fields
+}
+
+// 按照section中定义的schema检查和转换页面的元数据
+func (b *Builder) checkSchema(section *Section, meta Meta) error {
+	schema := section.Meta.GetStringMap("schema")
+	if len(schema) == 0 {
+		return nil
+	}
+	fields := parseSchema(schema)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		field := fields[k]
+		if !utils.CheckInList([]string{"", "any", "string", "int", "bool", "date", "list"}, field.Type) {
+			return fmt.Errorf("schema: unknown type %s of key %s", field.Type, k)
+		}
+
+		v, ok := meta[k]
+		if !ok || v == nil || v == "" {
+			if field.Default == nil {
+				if field.Required {
+					return fmt.Errorf("schema: key %s is required", k)
+				}
+				continue
+			}
+			v = field.Default
+		}
+		value, err := field.coerce(b.conf, v)
+		if err != nil {
+			return fmt.Errorf("schema: key %s must be %s, got %#v", k, field.Type, v)
+		}
+		if err := field.check(value); err != nil {
+			return fmt.Errorf("schema: key %s %s", k, err.Error())
+		}
+		meta[k] = value
+	}
+	return nil
+}
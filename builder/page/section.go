@@ -146,6 +146,7 @@ func (b *Builder) insertSection(path string) *Section {
 		section.Title = filepath.Base(section.File)
 	}
 	section.Meta.load(filemeta)
+	delete(section.Meta, "subtrees")
 
 	name := section.RealName()
 	if !section.isRoot() {
@@ -175,7 +176,11 @@ func (b *Builder) insertSection(path string) *Section {
 	section.Permalink = b.conf.GetURL(section.Path)
 	section.Formats = b.formats(section.Meta, section.realPath)
 
-	section = b.hooks.Section(section)
+	section, err := b.hooks.section(section)
+	if err != nil {
+		b.addError(fmt.Errorf("%s: %s", path, err.Error()))
+		return nil
+	}
 	if section == nil {
 		return nil
 	}
@@ -0,0 +1,418 @@
+package page
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/builder/theme/template"
+	"github.com/honmaple/snow/config"
+)
+
+var (
+	SHORTCODE_PLACEHOLDER = regexp.MustCompile(`<p>\s*SNOWSHORTCODE(\d{6})X\s*</p>|SNOWSHORTCODE(\d{6})X`)
+	SHORTCODE_ESCAPE      = regexp.MustCompile(`^\{\{[<%]\s*/\*((?s:.*?))\*/\s*[>%]\}\}`)
+)
+
+type (
+	Shortcode struct {
+		Name   string
+		Args   []interface{}
+		Params map[string]interface{}
+		Body   string
+		// {{% %}}的内容会使用对应的markup渲染
+		Markup bool
+		Line   int
+	}
+	Shortcodes []*Shortcode
+
+	ShortcodeError struct {
+		Line int
+		Err  error
+	}
+	// 在渲染markup之前将shortcode替换为占位符, 页面生成后再替换为shortcode的输出
+	ShortcodeParser struct {
+		src        []byte
+		line       int
+		render     func([]byte) (string, error)
+		shortcodes Shortcodes
+	}
+	shortcodeTag struct {
+		name    string
+		args    []interface{}
+		params  map[string]interface{}
+		markup  bool
+		closing bool
+		closed  bool
+		escaped string
+		match   int
+		start   int
+		end     int
+	}
+)
+
+func (e *ShortcodeError) Error() string {
+	return fmt.Sprintf("shortcode line %d: %s", e.Line, e.Err.Error())
+}
+
+func (sc *Shortcode) Get(k interface{}) interface{} {
+	switch key := k.(type) {
+	case int:
+		if key >= 0 && key < len(sc.Args) {
+			return sc.Args[key]
+		}
+		return nil
+	case string:
+		return sc.Params[key]
+	}
+	return nil
+}
+
+func shortcodePlaceholder(index int) string {
+	return fmt.Sprintf("SNOWSHORTCODE%06dX", index)
+}
+
+func (p *ShortcodeParser) errorf(pos int, format string, args ...interface{}) error {
+	return &ShortcodeError{
+		Line: p.line + bytes.Count(p.src[:pos], []byte("\n")),
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+func shortcodeValue(s string, quoted bool) interface{} {
+	if quoted {
+		return s
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// 读取带引号或者不带引号的值
+func (p *ShortcodeParser) readValue(pos int) (interface{}, int, error) {
+	src := p.src
+	switch src[pos] {
+	case '"':
+		var b strings.Builder
+		for i := pos + 1; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				if i+1 < len(src) {
+					i++
+					b.WriteByte(src[i])
+				}
+			case '"':
+				return shortcodeValue(b.String(), true), i + 1, nil
+			default:
+				b.WriteByte(src[i])
+			}
+		}
+		return nil, pos, p.errorf(pos, "unterminated quoted string")
+	case '`':
+		end := bytes.IndexByte(src[pos+1:], '`')
+		if end < 0 {
+			return nil, pos, p.errorf(pos, "unterminated raw string")
+		}
+		return string(src[pos+1 : pos+1+end]), pos + end + 2, nil
+	}
+	i := pos
+	for i < len(src) && !isShortcodeSpace(src[i]) && src[i] != '=' && !bytes.HasPrefix(src[i:], []byte(">}}")) && !bytes.HasPrefix(src[i:], []byte("%}}")) {
+		i++
+	}
+	return shortcodeValue(string(src[pos:i]), false), i, nil
+}
+
+func isShortcodeSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// {{< name arg key="value" >}}, {{% name %}}, {{< /name >}}, {{< name />}}
+func (p *ShortcodeParser) readTag(pos int) (*shortcodeTag, error) {
+	src := p.src
+	tag := &shortcodeTag{start: pos, markup: src[pos+2] == '%'}
+
+	end := []byte(">}}")
+	if tag.markup {
+		end = []byte("%}}")
+	}
+
+	skip := func(i int) int {
+		for i < len(src) && isShortcodeSpace(src[i]) {
+			i++
+		}
+		return i
+	}
+
+	i := skip(pos + 3)
+	if i < len(src) && src[i] == '/' {
+		tag.closing = true
+		i = skip(i + 1)
+	}
+	start := i
+	for i < len(src) && !isShortcodeSpace(src[i]) && src[i] != '/' && !bytes.HasPrefix(src[i:], end) {
+		i++
+	}
+	tag.name = string(src[start:i])
+	if tag.name == "" {
+		return nil, p.errorf(pos, "shortcode name is missing")
+	}
+
+	tag.params = make(map[string]interface{})
+	for {
+		i = skip(i)
+		if i >= len(src) {
+			return nil, p.errorf(pos, "shortcode %s is not closed with %s", tag.name, string(end))
+		}
+		if bytes.HasPrefix(src[i:], end) {
+			tag.end = i + len(end)
+			return tag, nil
+		}
+		if src[i] == '/' {
+			if j := skip(i + 1); bytes.HasPrefix(src[j:], end) {
+				tag.closed = true
+				tag.end = j + len(end)
+				return tag, nil
+			}
+		}
+		if tag.closing {
+			return nil, p.errorf(i, "closing shortcode %s can't have parameters", tag.name)
+		}
+
+		value, j, err := p.readValue(i)
+		if err != nil {
+			return nil, err
+		}
+		if j < len(src) && src[j] == '=' {
+			key, ok := value.(string)
+			if !ok || j == i {
+				return nil, p.errorf(i, "invalid parameter name of shortcode %s", tag.name)
+			}
+			if j+1 >= len(src) || isShortcodeSpace(src[j+1]) {
+				return nil, p.errorf(j, "parameter %s of shortcode %s has no value", key, tag.name)
+			}
+			value, j, err = p.readValue(j + 1)
+			if err != nil {
+				return nil, err
+			}
+			tag.params[key] = value
+		} else {
+			tag.args = append(tag.args, value)
+		}
+		if j == i {
+			return nil, p.errorf(i, "invalid character %q in shortcode %s", src[i], tag.name)
+		}
+		i = j
+	}
+}
+
+// 读取所有的shortcode标签, {{</* name */>}} 会原样输出 {{< name >}}
+func (p *ShortcodeParser) tokenize() ([]*shortcodeTag, error) {
+	var (
+		pos  = 0
+		tags = make([]*shortcodeTag, 0)
+	)
+	for {
+		i := bytes.Index(p.src[pos:], []byte("{{"))
+		if i < 0 || pos+i+2 >= len(p.src) {
+			return tags, nil
+		}
+		i = pos + i
+		pos = i + 2
+
+		c := p.src[i+2]
+		if c != '<' && c != '%' {
+			continue
+		}
+		if match := SHORTCODE_ESCAPE.FindSubmatch(p.src[i:]); match != nil {
+			closing := '%'
+			if c == '<' {
+				closing = '>'
+			}
+			tags = append(tags, &shortcodeTag{
+				start:   i,
+				end:     i + len(match[0]),
+				escaped: fmt.Sprintf("{{%c %s %c}}", c, bytes.TrimSpace(match[1]), closing),
+			})
+			pos = i + len(match[0])
+			continue
+		}
+		tag, err := p.readTag(i)
+		if err != nil {
+			return nil, err
+		}
+		tag.match = -1
+		tags = append(tags, tag)
+		pos = tag.end
+	}
+}
+
+func (p *ShortcodeParser) write(w *bytes.Buffer, tags []*shortcodeTag, from, to, pos, end int) error {
+	for i := from; i < to; i++ {
+		tag := tags[i]
+		w.Write(p.src[pos:tag.start])
+		pos = tag.end
+
+		if tag.escaped != "" {
+			w.WriteString(tag.escaped)
+			continue
+		}
+		sc := &Shortcode{
+			Name:   tag.name,
+			Args:   tag.args,
+			Params: tag.params,
+			Markup: tag.markup,
+			Line:   p.line + bytes.Count(p.src[:tag.start], []byte("\n")),
+		}
+		if tag.match >= 0 {
+			var body bytes.Buffer
+
+			closing := tags[tag.match]
+			if err := p.write(&body, tags, i+1, tag.match, tag.end, closing.start); err != nil {
+				return err
+			}
+			sc.Body = body.String()
+			if sc.Markup && p.render != nil {
+				out, err := p.render(body.Bytes())
+				if err != nil {
+					return &ShortcodeError{Line: sc.Line, Err: err}
+				}
+				sc.Body = out
+			}
+			i = tag.match
+			pos = closing.end
+		}
+		p.shortcodes = append(p.shortcodes, sc)
+		w.WriteString(shortcodePlaceholder(len(p.shortcodes) - 1))
+	}
+	w.Write(p.src[pos:end])
+	return nil
+}
+
+// Parse 替换内容中的shortcode, line为内容在文件中的起始行
+func (p *ShortcodeParser) Parse(src []byte, line int) ([]byte, error) {
+	if !bytes.Contains(src, []byte("{{<")) && !bytes.Contains(src, []byte("{{%")) {
+		return src, nil
+	}
+	p.src = src
+	p.line = line
+
+	tags, err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
+	// 没有结束标签的shortcode没有内容
+	stack := make([]int, 0)
+	for i, tag := range tags {
+		if tag.escaped != "" {
+			continue
+		}
+		if !tag.closing {
+			if !tag.closed {
+				stack = append(stack, i)
+			}
+			continue
+		}
+		j := len(stack) - 1
+		for ; j >= 0; j-- {
+			if tags[stack[j]].name == tag.name {
+				break
+			}
+		}
+		if j < 0 {
+			return nil, p.errorf(tag.start, "unexpected closing shortcode %s", tag.name)
+		}
+		tags[stack[j]].match = i
+		stack = stack[:j]
+	}
+
+	var w bytes.Buffer
+	if err := p.write(&w, tags, 0, len(tags), 0, len(src)); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+func (p *ShortcodeParser) Shortcodes() Shortcodes {
+	return p.shortcodes
+}
+
+func NewShortcodeParser(render func([]byte) (string, error)) *ShortcodeParser {
+	return &ShortcodeParser{render: render}
+}
+
+// 优先使用主题中的模版, 其次是使用Go注册的shortcode
+func (b *Builder) renderShortcode(page *Page, sc *Shortcode, body string, counter int) (string, error) {
+	var tpl template.Writer
+	if b.theme != nil {
+		tpl = b.theme.LookupTemplate(
+			"shortcodes/"+sc.Name+".html",
+			"shortcodes/"+sc.Name+"/index.html",
+			"_internal/shortcodes/"+sc.Name+".html",
+		)
+	}
+	if tpl == nil {
+		if fn, ok := b.shortcodes[sc.Name]; ok {
+			return fn(page, sc, body)
+		}
+		return "", fmt.Errorf("shortcode %s not found", sc.Name)
+	}
+	return tpl.Execute(map[string]interface{}{
+		"page":         page,
+		"body":         body,
+		"args":         sc.Args,
+		"params":       sc.Params,
+		"shortcode":    sc,
+		"current_lang": page.Lang,
+		"_name":        sc.Name,
+		"_counter":     counter,
+	})
+}
+
+// 替换页面内容中的占位符, 单独成段的shortcode会去掉外层的<p>
+func (b *Builder) renderShortcodes(page *Page, content string, shortcodes Shortcodes, counter map[string]int) string {
+	if !strings.Contains(content, "SNOWSHORTCODE") {
+		return content
+	}
+	return SHORTCODE_PLACEHOLDER.ReplaceAllStringFunc(content, func(s string) string {
+		match := SHORTCODE_PLACEHOLDER.FindStringSubmatch(s)
+		index, _ := strconv.Atoi(match[1] + match[2])
+		if index >= len(shortcodes) {
+			return s
+		}
+		sc := shortcodes[index]
+		body := b.renderShortcodes(page, sc.Body, shortcodes, counter)
+
+		out, err := b.renderShortcode(page, sc, body, counter[sc.Name])
+		counter[sc.Name]++
+		if err != nil {
+			b.addError(fmt.Errorf("%s: %s", page.File, (&ShortcodeError{Line: sc.Line, Err: err}).Error()))
+			return ""
+		}
+		return out
+	})
+}
+
+type (
+	ShortcodeFunc    func(*Page, *Shortcode, string) (string, error)
+	shortcodeCreator func(config.Config, theme.Theme) ShortcodeFunc
+)
+
+var _shortcodes = make(map[string]shortcodeCreator)
+
+// RegisterShortcode 注册Go实现的shortcode, 主题中存在同名模版时会优先使用模版
+func RegisterShortcode(name string, c shortcodeCreator) {
+	_shortcodes[name] = c
+}
@@ -0,0 +1,37 @@
+package shortcode
+
+import (
+	"strings"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+)
+
+// {{< highlight go "linenos=table,hl_lines=2 4-5,linenostart=10" >}}code{{< /highlight >}}
+func highlight(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+	return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+		lang := param(sc, "lang", 0)
+
+		opts := make(map[string]string)
+		for k, v := range sc.Params {
+			opts[k] = cast.ToString(v)
+		}
+		if len(sc.Args) > 1 {
+			for _, opt := range utils.SplitTrim(cast.ToString(sc.Args[1]), ",") {
+				if kv := strings.SplitN(opt, "=", 2); len(kv) == 2 {
+					opts[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+				}
+			}
+		}
+		code := strings.Trim(body, "\n")
+
+		name := opts["style"]
+		if name == "" {
+			name = conf.GetHighlightStyle()
+		}
+		return utils.Highlight(code, lang, name, opts)
+	}
+}
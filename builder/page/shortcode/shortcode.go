@@ -0,0 +1,249 @@
+package shortcode
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/builder/theme"
+	"github.com/honmaple/snow/config"
+	"github.com/spf13/cast"
+)
+
+var (
+	SHORTCODE_HEADING = regexp.MustCompile(`(?s)<h([1-6])([^>]*)>(.*?)</h[1-6]>`)
+	SHORTCODE_ID      = regexp.MustCompile(`\sid="([^"]+)"`)
+	SHORTCODE_TAG     = regexp.MustCompile(`<[^>]+>`)
+)
+
+// 按照名称或者位置读取参数
+func param(sc *page.Shortcode, name string, index int) string {
+	if v, ok := sc.Params[name]; ok {
+		return cast.ToString(v)
+	}
+	if index >= 0 && index < len(sc.Args) {
+		return cast.ToString(sc.Args[index])
+	}
+	return ""
+}
+
+func attrs(kvs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kvs); i += 2 {
+		if kvs[i+1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, " %s=\"%s\"", kvs[i], html.EscapeString(kvs[i+1]))
+	}
+	return b.String()
+}
+
+// {{< figure src="cover.png" alt="cover" caption="说明" link="https://example.com" >}}
+func figure(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+	return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+		src := param(sc, "src", 0)
+		if src == "" {
+			return "", fmt.Errorf("figure: src is required")
+		}
+		src, _ = p.Resource(src)
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "<figure%s>", attrs("class", param(sc, "class", -1)))
+
+		link := param(sc, "link", -1)
+		if link != "" {
+			fmt.Fprintf(&b, "<a%s>", attrs("href", link, "target", param(sc, "target", -1)))
+		}
+		alt := param(sc, "alt", -1)
+		if alt == "" {
+			alt = param(sc, "caption", 1)
+		}
+		fmt.Fprintf(&b, "<img%s/>", attrs(
+			"src", src,
+			"alt", alt,
+			"title", param(sc, "title", -1),
+			"width", param(sc, "width", -1),
+			"height", param(sc, "height", -1),
+			"loading", param(sc, "loading", -1),
+		))
+		if link != "" {
+			b.WriteString("</a>")
+		}
+		caption := param(sc, "caption", 1)
+		if caption == "" {
+			caption = strings.TrimSpace(body)
+		} else {
+			caption = html.EscapeString(caption)
+		}
+		if title := param(sc, "title", -1); title != "" || caption != "" {
+			b.WriteString("<figcaption>")
+			if title != "" {
+				fmt.Fprintf(&b, "<h4>%s</h4>", html.EscapeString(title))
+			}
+			b.WriteString(caption)
+			b.WriteString("</figcaption>")
+		}
+		b.WriteString("</figure>")
+		return b.String(), nil
+	}
+}
+
+// {{< youtube id="w7Ft2ymGmfc" start=10 >}}
+func youtube(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+	return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+		id := param(sc, "id", 0)
+		if id == "" {
+			return "", fmt.Errorf("youtube: id is required")
+		}
+		query := make([]string, 0)
+		if cast.ToBool(sc.Params["autoplay"]) {
+			query = append(query, "autoplay=1")
+		}
+		if start := param(sc, "start", -1); start != "" {
+			query = append(query, "start="+start)
+		}
+		src := "https://www.youtube-nocookie.com/embed/" + id
+		if len(query) > 0 {
+			src = src + "?" + strings.Join(query, "&")
+		}
+		title := param(sc, "title", -1)
+		if title == "" {
+			title = "YouTube Video"
+		}
+		class := param(sc, "class", -1)
+		if class == "" {
+			class = "video-container"
+		}
+		return fmt.Sprintf(`<div%s><iframe%s allow="accelerometer; autoplay; clipboard-write; encrypted-media; gyroscope; picture-in-picture" allowfullscreen></iframe></div>`,
+			attrs("class", class),
+			attrs("src", src, "title", title, "frameborder", "0"),
+		), nil
+	}
+}
+
+// {{< video src="demo.mp4" poster="demo.png" autoplay=true >}}
+func video(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+	return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+		src := param(sc, "src", 0)
+		if src == "" {
+			return "", fmt.Errorf("video: src is required")
+		}
+		src, _ = p.Resource(src)
+		poster, _ := p.Resource(param(sc, "poster", -1))
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "<video%s", attrs(
+			"src", src,
+			"poster", poster,
+			"class", param(sc, "class", -1),
+			"width", param(sc, "width", -1),
+			"height", param(sc, "height", -1),
+		))
+		for _, k := range []string{"autoplay", "loop", "muted"} {
+			if cast.ToBool(sc.Params[k]) {
+				b.WriteString(" " + k)
+			}
+		}
+		if v, ok := sc.Params["controls"]; !ok || cast.ToBool(v) {
+			b.WriteString(" controls")
+		}
+		b.WriteString(" playsinline>")
+		b.WriteString(body)
+		b.WriteString("</video>")
+		return b.String(), nil
+	}
+}
+
+// {{< details summary="更多" open=true >}}内容{{< /details >}}
+func details(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+	return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+		summary := param(sc, "summary", 0)
+		if summary == "" {
+			summary = "Details"
+		}
+		open := ""
+		if cast.ToBool(sc.Params["open"]) {
+			open = " open"
+		}
+		return fmt.Sprintf("<details%s%s><summary>%s</summary>%s</details>", attrs("class", param(sc, "class", -1)), open, html.EscapeString(summary), body), nil
+	}
+}
+
+// [链接]({{< ref "other.md" >}}) 或者 {{< relref "other.md#anchor" >}}文本{{< /relref >}}
+func ref(kind string) func(config.Config, theme.Theme) page.ShortcodeFunc {
+	return func(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+		return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+			path := param(sc, "path", 0)
+			if path == "" {
+				return "", fmt.Errorf("%s: path is required", kind)
+			}
+			// 链接在所有页面生成后替换
+			link := kind + ":" + path
+			if body == "" {
+				return link, nil
+			}
+			return fmt.Sprintf("<a%s>%s</a>", attrs("href", link), body), nil
+		}
+	}
+}
+
+// {{< toc >}}, {{< toc min=2 max=3 >}}
+func toc(conf config.Config, theme theme.Theme) page.ShortcodeFunc {
+	return func(p *page.Page, sc *page.Shortcode, body string) (string, error) {
+		min, max := 1, 6
+		if v, ok := sc.Params["min"]; ok {
+			min = cast.ToInt(v)
+		}
+		if v, ok := sc.Params["max"]; ok {
+			max = cast.ToInt(v)
+		}
+
+		var (
+			b      strings.Builder
+			levels = make([]int, 0)
+		)
+		for _, match := range SHORTCODE_HEADING.FindAllStringSubmatch(p.Content, -1) {
+			l := cast.ToInt(match[1])
+			if l < min || l > max {
+				continue
+			}
+			if len(levels) == 0 || l > levels[len(levels)-1] {
+				b.WriteString("<ul>")
+				levels = append(levels, l)
+			} else {
+				for len(levels) > 1 && l < levels[len(levels)-1] {
+					b.WriteString("</li></ul>")
+					levels = levels[:len(levels)-1]
+				}
+				b.WriteString("</li>")
+			}
+			text := strings.TrimSpace(SHORTCODE_TAG.ReplaceAllString(match[3], ""))
+			// 没有id的标题不生成链接
+			if id := SHORTCODE_ID.FindStringSubmatch(match[2]); id != nil {
+				fmt.Fprintf(&b, "<li><a href=\"#%s\">%s</a>", id[1], text)
+			} else {
+				fmt.Fprintf(&b, "<li>%s", text)
+			}
+		}
+		if len(levels) == 0 {
+			return "", nil
+		}
+		for range levels {
+			b.WriteString("</li></ul>")
+		}
+		return fmt.Sprintf("<nav%s>%s</nav>", attrs("class", "toc"), b.String()), nil
+	}
+}
+
+func init() {
+	page.RegisterShortcode("figure", figure)
+	page.RegisterShortcode("youtube", youtube)
+	page.RegisterShortcode("video", video)
+	page.RegisterShortcode("details", details)
+	page.RegisterShortcode("ref", ref("ref"))
+	page.RegisterShortcode("relref", ref("relref"))
+	page.RegisterShortcode("toc", toc)
+	page.RegisterShortcode("highlight", highlight)
+}
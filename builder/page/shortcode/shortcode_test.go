@@ -0,0 +1,22 @@
+package shortcode
+
+import (
+	"testing"
+
+	"github.com/honmaple/snow/builder/page"
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToc(t *testing.T) {
+	fn := toc(config.DefaultConfig(), nil)
+
+	p := &page.Page{Content: `<h2 id="a">A</h2><h3 id="b"><code>B</code></h3><h2>C</h2><h4 id="d">D</h4>`}
+	out, err := fn(p, &page.Shortcode{}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, `<nav class="toc"><ul><li><a href="#a">A</a><ul><li><a href="#b">B</a></li></ul></li><li>C<ul><li><a href="#d">D</a></li></ul></li></ul></nav>`, out)
+
+	out, err = fn(p, &page.Shortcode{Params: map[string]interface{}{"max": 2}}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, `<nav class="toc"><ul><li><a href="#a">A</a></li><li>C</li></ul></nav>`, out)
+}
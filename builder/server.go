@@ -101,7 +101,13 @@ func (m *memoryServer) Build(ctx context.Context) error {
 				}
 				if event.Op == fsnotify.Write {
 					m.conf.Log.Infoln("The", event.Name, "has been modified. Rebuilding...")
-					m.conf.Cache.Delete(event.Name)
+					// 非页面文件(比如被引用的代码文件)无法确定影响了哪些页面, 清除所有缓存
+					if _, ok := m.conf.Cache.LoadAndDelete(event.Name); !ok {
+						m.conf.Cache.Range(func(k, v interface{}) bool {
+							m.conf.Cache.Delete(k)
+							return true
+						})
+					}
 					m.reset()
 					if err := Build(m.conf); err != nil {
 						m.conf.Log.Errorln("Build error", err.Error())
@@ -2,6 +2,8 @@ package static
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -18,6 +20,7 @@ type Builder struct {
 	conf  config.Config
 	theme theme.Theme
 	hooks Hooks
+	start time.Time
 }
 
 func (b *Builder) ignoreFile(path string) func(file string) bool {
@@ -50,17 +53,25 @@ func (b *Builder) ignoreFile(path string) func(file string) bool {
 	}
 }
 
-func (b *Builder) Build(ctx context.Context) error {
-	now := time.Now()
-	defer func() {
-		if count := len(b.ctx.Statics()); count > 0 {
-			lang := ""
-			if !b.conf.IsDefaultLanguage(b.conf.Site.Language) {
-				lang = "[" + b.conf.Site.Language + "]"
-			}
-			b.conf.Log.Infoln("Done:", lang+"Static Processed", count, "static files", "in", time.Now().Sub(now))
+func (b *Builder) Context() *Context {
+	return b.ctx
+}
+
+func (b *Builder) report() {
+	if count := len(b.ctx.Statics()); count > 0 {
+		lang := ""
+		if !b.conf.IsDefaultLanguage(b.conf.Site.Language) {
+			lang = "[" + b.conf.Site.Language + "]"
 		}
-	}()
+		b.conf.Log.Infoln("Done:", lang+"Static Processed", count, "static files", "in", time.Now().Sub(b.start))
+	}
+}
+
+// 读取所有静态文件, 不写入文件
+func (b *Builder) Read(ctx context.Context) error {
+	b.start = time.Now()
+
+	errs := make([]string, 0)
 
 	// 因为viper不能识别文件名中的".", 所以这里通过获取".path"的前缀来获取文件名
 	names := make([]string, 0)
@@ -103,11 +114,16 @@ func (b *Builder) Build(ctx context.Context) error {
 			staticFile.Path = b.conf.GetRelURL(staticFile.Path)
 			staticFile.Permalink = b.conf.GetURL(staticFile.Path)
 
-			staticFile = b.hooks.Static(staticFile)
+			staticFile, err = b.hooks.static(staticFile)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", file, err.Error()))
+				return nil
+			}
 			if staticFile == nil {
 				return nil
 			}
 			b.ctx.insertStatic(staticFile)
+			b.conf.SetSource(staticFile.Path, staticFile.open)
 			return nil
 		}
 
@@ -123,11 +139,20 @@ func (b *Builder) Build(ctx context.Context) error {
 		}
 		filepath.Walk(name, walkFunc)
 	}
-	return b.Write()
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
 }
 
 func (b *Builder) Write() error {
-	for _, static := range b.hooks.Statics(b.ctx.Statics()) {
+	defer b.report()
+
+	statics, err := b.hooks.statics(b.ctx.Statics())
+	if err != nil {
+		return err
+	}
+	for _, static := range statics {
 		// src := static.File.Name()
 		// dst := filepath.Join(b.conf.OutputDir, static.Path)
 		// b.conf.Log.Debugln("Copying", src, "to", dst)
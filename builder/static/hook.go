@@ -6,6 +6,14 @@ type (
 		Statics(Statics) Statics
 	}
 	Hooks []Hook
+
+	// 可选接口, 实现后会代替对应的方法, 返回的错误会中止构建
+	StaticHookE interface {
+		StaticE(*Static) (*Static, error)
+	}
+	StaticsHookE interface {
+		StaticsE(Statics) (Statics, error)
+	}
 )
 
 func (hooks Hooks) Static(static *Static) *Static {
@@ -27,3 +35,34 @@ func (hooks Hooks) Statics(statics Statics) Statics {
 	}
 	return statics
 }
+
+// 以下方法供构建时调用, 优先使用返回错误的接口
+func (hooks Hooks) static(static *Static) (*Static, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(StaticHookE); ok {
+			static, err = h.StaticE(static)
+		} else {
+			static = hook.Static(static)
+		}
+		if err != nil || static == nil {
+			return nil, err
+		}
+	}
+	return static, nil
+}
+
+func (hooks Hooks) statics(statics Statics) (Statics, error) {
+	var err error
+	for _, hook := range hooks {
+		if h, ok := hook.(StaticsHookE); ok {
+			statics, err = h.StaticsE(statics)
+		} else {
+			statics = hook.Statics(statics)
+		}
+		if err != nil || len(statics) == 0 {
+			return nil, err
+		}
+	}
+	return statics, nil
+}
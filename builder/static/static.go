@@ -1,6 +1,7 @@
 package static
 
 import (
+	"io"
 	"io/fs"
 	"strings"
 )
@@ -23,6 +24,10 @@ func (file Static) Open() (fs.File, error) {
 	return file.Root.Open(file.Name)
 }
 
+func (file Static) open() (io.ReadCloser, error) {
+	return file.Open()
+}
+
 func (statics Statics) Lookup(files []string) Statics {
 	m := make(map[string]bool)
 	for _, file := range files {
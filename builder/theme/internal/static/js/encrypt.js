@@ -1,4 +1,9 @@
-const _do_decrypt = (encrypted, password) => {
+const _b64decode = (s) => Uint8Array.from(atob(s), (c) => c.charCodeAt(0));
+
+// 旧的加密格式, 需要hooks.encrypt.legacy并加载CryptoJS
+const _do_decrypt_legacy = (encrypted, password) => {
+    password = CryptoJS.MD5(password).toString();
+
     let key = CryptoJS.enc.Utf8.parse(password);
     let iv = CryptoJS.enc.Utf8.parse(password.substr(0, 16));
 
@@ -9,16 +14,38 @@ const _do_decrypt = (encrypted, password) => {
     }).toString(CryptoJS.enc.Utf8);
 }
 
-const decrypt = (element) => {
-    let parent = element.parentNode.parentNode;
-    let password = parent.querySelector(".encrypt-password").value;
-    let encrypted = parent.querySelector(".encrypt-content").innerText;
+// v2$<iterations>$<salt>$<nonce>$<ciphertext>: PBKDF2-SHA256 + AES-GCM
+const _do_decrypt = async (encrypted, password) => {
+    let parts = encrypted.trim().split("$");
+    if (parts.length != 5 || parts[0] != "v2") {
+        return _do_decrypt_legacy(encrypted.trim(), password);
+    }
+    let [, iterations, salt, nonce, ciphertext] = parts;
 
-    password = CryptoJS.MD5(password).toString()
+    let material = await crypto.subtle.importKey(
+        "raw", new TextEncoder().encode(password), "PBKDF2", false, ["deriveKey"]
+    );
+    let key = await crypto.subtle.deriveKey(
+        {name: "PBKDF2", salt: _b64decode(salt), iterations: parseInt(iterations), hash: "SHA-256"},
+        material,
+        {name: "AES-GCM", length: 256},
+        false,
+        ["decrypt"]
+    );
+    let decrypted = await crypto.subtle.decrypt(
+        {name: "AES-GCM", iv: _b64decode(nonce)}, key, _b64decode(ciphertext)
+    );
+    return new TextDecoder().decode(decrypted);
+}
+
+const decrypt = async (element) => {
+    let parent = element.closest(".encrypt-container");
+    let password = parent.querySelector(".encrypt-password").value;
+    let encrypted = parent.querySelector(".encrypt-content").textContent;
 
     let decrypted = "";
     try {
-        decrypted = _do_decrypt(encrypted, password);
+        decrypted = await _do_decrypt(encrypted, password);
     } catch (err) {
         console.error(err);
         alert("Failed to decrypt.");
@@ -49,16 +76,18 @@ window.onload = () => {
         let password = sessionStorage.getItem(key);
 
         if (password) {
-            let encrypted = element.querySelector(".encrypt-content").innerText;
-            let decrypted = _do_decrypt(encrypted, password);
-            element.innerHTML = decrypted;
-        } else {
-            element.querySelector(".encrypt-form .encrypt-button").addEventListener("click", () => {
-                decrypt(element);
-            })
-            element.querySelector(".encrypt-form .encrypt-password").addEventListener("keypress", (e) => {
-                e.keyCode == 13 && decrypt(element);
-            })
+            let encrypted = element.querySelector(".encrypt-content").textContent;
+            _do_decrypt(encrypted, password).then((decrypted) => {
+                element.innerHTML = decrypted;
+            }).catch(() => {
+                sessionStorage.removeItem(key);
+            });
         }
+        element.querySelector(".encrypt-form .encrypt-button").addEventListener("click", () => {
+            decrypt(element);
+        })
+        element.querySelector(".encrypt-form .encrypt-password").addEventListener("keypress", (e) => {
+            e.keyCode == 13 && decrypt(element);
+        })
     });
-};
\ No newline at end of file
+};
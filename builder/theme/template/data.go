@@ -0,0 +1,86 @@
+package template
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+)
+
+func setData(data map[string]interface{}, keys []string, value interface{}) {
+	for _, key := range keys[:len(keys)-1] {
+		m, ok := data[key].(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{})
+			data[key] = m
+		}
+		data = m
+	}
+	data[keys[len(keys)-1]] = value
+}
+
+// data/team.yaml -> data.team, data/projects/snow.json -> data.projects.snow,
+// data/team.zh.yaml 会覆盖中文站点中的 data.team
+func loadData(conf *config.Config, lang string) map[string]interface{} {
+	data := make(map[string]interface{})
+
+	dir := conf.GetString("data_dir")
+	if dir == "" || !utils.FileExists(dir) {
+		return data
+	}
+	conf.Watch(dir)
+
+	files := make([]string, 0)
+	overrides := make([]string, 0)
+	filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if !utils.CheckInList(utils.DataFormats, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		name := utils.FileBaseName(path)
+		if ext := filepath.Ext(name); ext != "" && conf.IsValidLanguage(ext[1:]) {
+			if ext[1:] == lang {
+				overrides = append(overrides, path)
+			}
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	sort.Strings(files)
+	sort.Strings(overrides)
+
+	for _, file := range append(files, overrides...) {
+		value, err := utils.ReadDataFile(file)
+		if err != nil {
+			conf.Log.Errorf("Read data file %s: %s", file, err.Error())
+			continue
+		}
+		rel, _ := filepath.Rel(dir, file)
+		name := utils.FileBaseName(rel)
+		if ext := filepath.Ext(name); ext != "" && conf.IsValidLanguage(ext[1:]) {
+			name = name[:len(name)-len(ext)]
+		}
+		setData(data, strings.Split(filepath.ToSlash(filepath.Join(filepath.Dir(rel), name)), "/"), value)
+	}
+	return data
+}
+
+func newData(conf config.Config) func(map[string]interface{}) interface{} {
+	langs := make(map[string]interface{})
+	for lang, c := range conf.Languages {
+		langs[lang] = loadData(c, lang)
+	}
+	return func(ctx map[string]interface{}) interface{} {
+		lang := ctx["current_lang"]
+		if lang == nil {
+			return langs[conf.Site.Language]
+		}
+		return langs[lang.(string)]
+	}
+}
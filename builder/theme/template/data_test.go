@@ -0,0 +1,64 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestData(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"team.yaml":          "- name: a\n  date: 2023-02-24\n",
+		"team.zh.yaml":       "- name: 甲\n",
+		"links.toml":         "github = \"https://github.com\"\n",
+		"users.csv":          "name, age\nx, 1\n",
+		"projects/snow.json": `{"stars": 1}`,
+		"readme.txt":         "ignored",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+	}
+
+	conf := config.DefaultConfig()
+	conf.Set("data_dir", dir)
+	conf.Set("languages.zh.title", "zh")
+	conf.Init()
+
+	data := newData(conf)
+	tests := []struct {
+		lang   interface{}
+		expect map[string]interface{}
+	}{
+		{
+			lang: nil,
+			expect: map[string]interface{}{
+				"team":     []interface{}{map[string]interface{}{"name": "a", "date": "2023-02-24"}},
+				"links":    map[string]interface{}{"github": "https://github.com"},
+				"users":    []interface{}{map[string]interface{}{"name": "x", "age": "1"}},
+				"projects": map[string]interface{}{"snow": map[string]interface{}{"stars": float64(1)}},
+			},
+		},
+		{
+			lang: "zh",
+			expect: map[string]interface{}{
+				"team":     []interface{}{map[string]interface{}{"name": "甲"}},
+				"links":    map[string]interface{}{"github": "https://github.com"},
+				"users":    []interface{}{map[string]interface{}{"name": "x", "age": "1"}},
+				"projects": map[string]interface{}{"snow": map[string]interface{}{"stars": float64(1)}},
+			},
+		},
+	}
+	for _, test := range tests {
+		ctx := make(map[string]interface{})
+		if test.lang != nil {
+			ctx["current_lang"] = test.lang
+		}
+		assert.Equal(t, test.expect, data(ctx))
+	}
+}
@@ -185,6 +185,7 @@ func init() {
 	RegisterFilter("jsonify", jsonify)
 
 	RegisterConfigFunc("config", newConfig)
+	RegisterConfigFunc("data", newData)
 
 	RegisterConfigFilter("absURL", absURL)
 	RegisterConfigFilter("relURL", relURL)
@@ -0,0 +1,321 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/honmaple/snow/config"
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	minhtml "github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/json"
+	"github.com/tdewolff/minify/v2/xml"
+	"golang.org/x/net/html"
+)
+
+func getAttr(token *html.Token, key string) (string, bool) {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func setAttr(token *html.Token, key, val string) {
+	for i, attr := range token.Attr {
+		if attr.Key == key {
+			token.Attr[i].Val = val
+			return
+		}
+	}
+	token.Attr = append(token.Attr, html.Attribute{Key: key, Val: val})
+}
+
+// 在属性中添加值, 比如 rel="nofollow" -> rel="nofollow noopener"
+func addAttr(token *html.Token, key, val string) bool {
+	old, _ := getAttr(token, key)
+	values := strings.Fields(old)
+	for _, v := range values {
+		if v == val {
+			return false
+		}
+	}
+	setAttr(token, key, strings.Join(append(values, val), " "))
+	return true
+}
+
+// 只重新生成修改过的标签, 其它内容原样输出
+func rewrite(data []byte, tags []string, f func(*html.Token) bool) ([]byte, error) {
+	var (
+		b bytes.Buffer
+		z = html.NewTokenizer(bytes.NewReader(data))
+	)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		raw := append([]byte(nil), z.Raw()...)
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			token := z.Token()
+			if utils.CheckInList(tags, token.Data) && f(&token) {
+				b.WriteString(token.String())
+				continue
+			}
+		}
+		b.Write(raw)
+	}
+	return b.Bytes(), nil
+}
+
+func isHTML(file string) bool {
+	return utils.MediaType(file) == "text/html"
+}
+
+// 输出文件所在的目录, posts/first/index.html -> /posts/first
+func outputDir(file string) string {
+	return path.Dir("/" + strings.TrimPrefix(filepath.ToSlash(file), "/"))
+}
+
+// 站点链接转换为输出目录中的路径, https://example.com/static/main.css -> /static/main.css
+func sitePath(site *url.URL, link string) (string, bool) {
+	if site != nil && site.Host != "" {
+		prefix := site.Scheme + "://" + site.Host + strings.TrimSuffix(site.Path, "/")
+		if link == prefix {
+			return "/", true
+		}
+		if strings.HasPrefix(link, prefix) {
+			rest := link[len(prefix):]
+			if strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "?") || strings.HasPrefix(rest, "#") {
+				return "/" + strings.TrimPrefix(rest, "/"), true
+			}
+			return "", false
+		}
+	}
+	if strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//") {
+		if site != nil && site.Path != "" && site.Path != "/" {
+			prefix := strings.TrimSuffix(site.Path, "/")
+			if link != prefix && !strings.HasPrefix(link, prefix+"/") {
+				return "", false
+			}
+			return "/" + strings.TrimPrefix(link[len(prefix):], "/"), true
+		}
+		return link, true
+	}
+	return "", false
+}
+
+func relPath(dir string, link string) string {
+	suffix := ""
+	if idx := strings.IndexAny(link, "?#"); idx >= 0 {
+		link, suffix = link[:idx], link[idx:]
+	}
+	rel, err := filepath.Rel(dir, link)
+	if err != nil {
+		return link + suffix
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(link, "/") && rel != "." {
+		rel = rel + "/"
+	}
+	if rel == "." {
+		rel = "./"
+	}
+	return rel + suffix
+}
+
+func minifyTransformer(conf config.Config, opts map[string]interface{}) config.Transformer {
+	m := minify.New()
+	m.Add("text/html", &minhtml.Minifier{
+		KeepDocumentTags: true,
+		KeepEndTags:      true,
+	})
+	m.AddFunc("text/css", css.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("^(application|text)/(x-)?(java|ecma)script$"), js.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]json$"), json.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]xml$"), xml.Minify)
+
+	return func(file string, data []byte) ([]byte, error) {
+		out, err := m.Bytes(utils.MediaType(file), data)
+		if err == minify.ErrNotExist {
+			return data, nil
+		}
+		return out, err
+	}
+}
+
+// https://example.com/static/main.css -> ../../static/main.css
+func relativeURLs(conf config.Config, opts map[string]interface{}) config.Transformer {
+	site, _ := url.Parse(conf.Site.URL)
+	keys := cast.ToStringSlice(opts["attrs"])
+	if len(keys) == 0 {
+		keys = []string{"href", "src", "poster"}
+	}
+	tags := []string{"a", "link", "img", "script", "source", "video", "audio", "iframe"}
+
+	return func(file string, data []byte) ([]byte, error) {
+		if !isHTML(file) {
+			return data, nil
+		}
+		dir := outputDir(file)
+		return rewrite(data, tags, func(token *html.Token) bool {
+			changed := false
+			for i, attr := range token.Attr {
+				if !utils.CheckInList(keys, attr.Key) {
+					continue
+				}
+				link, ok := sitePath(site, attr.Val)
+				if !ok {
+					continue
+				}
+				token.Attr[i].Val = relPath(dir, link)
+				changed = true
+			}
+			return changed
+		})
+	}
+}
+
+// <img src="a.png"> -> <img src="a.png" loading="lazy" width="800" height="600">
+// 图片大小从源文件(静态文件, 主题和页面引用的文件)中读取, 不依赖输出目录, 缓存只在一次构建中有效
+func lazyImages(conf config.Config, opts map[string]interface{}) config.Transformer {
+	site, _ := url.Parse(conf.Site.URL)
+	dirs := cast.ToStringSlice(opts["dirs"])
+
+	open := func(file string) (io.ReadCloser, error) {
+		if f, err := conf.OpenSource(file); err == nil {
+			return f, nil
+		}
+		for _, dir := range dirs {
+			if f, err := os.Open(filepath.Join(dir, filepath.FromSlash(file))); err == nil {
+				return f, nil
+			}
+		}
+		return nil, os.ErrNotExist
+	}
+
+	var sizes sync.Map
+	size := func(file string) (image.Config, bool) {
+		if v, ok := sizes.Load(file); ok {
+			c, ok := v.(image.Config)
+			return c, ok
+		}
+		f, err := open(file)
+		if err != nil {
+			sizes.Store(file, false)
+			return image.Config{}, false
+		}
+		c, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			sizes.Store(file, false)
+			return image.Config{}, false
+		}
+		sizes.Store(file, c)
+		return c, true
+	}
+
+	return func(file string, data []byte) ([]byte, error) {
+		if !isHTML(file) {
+			return data, nil
+		}
+		dir := outputDir(file)
+		return rewrite(data, []string{"img"}, func(token *html.Token) bool {
+			changed := false
+			if _, ok := getAttr(token, "loading"); !ok {
+				setAttr(token, "loading", "lazy")
+				changed = true
+			}
+			_, hasWidth := getAttr(token, "width")
+			_, hasHeight := getAttr(token, "height")
+			if hasWidth || hasHeight {
+				return changed
+			}
+			src, _ := getAttr(token, "src")
+			link, ok := sitePath(site, src)
+			if !ok {
+				if src == "" || strings.Contains(src, ":") || strings.HasPrefix(src, "//") {
+					return changed
+				}
+				link = path.Join(dir, src)
+			}
+			if idx := strings.IndexAny(link, "?#"); idx >= 0 {
+				link = link[:idx]
+			}
+			if v, err := url.PathUnescape(link); err == nil {
+				link = v
+			}
+			if c, ok := size(link); ok {
+				setAttr(token, "width", strconv.Itoa(c.Width))
+				setAttr(token, "height", strconv.Itoa(c.Height))
+				changed = true
+			}
+			return changed
+		})
+	}
+}
+
+// <a href="https://github.com"> -> <a href="https://github.com" rel="noopener" class="external">
+func externalLinks(conf config.Config, opts map[string]interface{}) config.Transformer {
+	site, _ := url.Parse(conf.Site.URL)
+	class := "external"
+	if v, ok := opts["class"]; ok {
+		class = cast.ToString(v)
+	}
+	rels := []string{"noopener"}
+	if v, ok := opts["rel"]; ok {
+		rels = strings.Fields(cast.ToString(v))
+	}
+
+	return func(file string, data []byte) ([]byte, error) {
+		if !isHTML(file) {
+			return data, nil
+		}
+		return rewrite(data, []string{"a"}, func(token *html.Token) bool {
+			href, _ := getAttr(token, "href")
+			u, err := url.Parse(href)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return false
+			}
+			if site != nil && strings.EqualFold(u.Host, site.Host) {
+				return false
+			}
+			changed := false
+			for _, rel := range rels {
+				if addAttr(token, "rel", rel) {
+					changed = true
+				}
+			}
+			if class != "" && addAttr(token, "class", class) {
+				changed = true
+			}
+			return changed
+		})
+	}
+}
+
+func init() {
+	config.RegisterTransformer("minify", minifyTransformer)
+	config.RegisterTransformer("relative_urls", relativeURLs)
+	config.RegisterTransformer("lazy_images", lazyImages)
+	config.RegisterTransformer("external_links", externalLinks)
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/honmaple/snow/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelativeURLs(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Site.URL = "https://example.com"
+	fn := relativeURLs(conf, nil)
+
+	out, err := fn("posts/first/index.html", []byte(`<a href="https://example.com/">home</a><link href="https://example.com/static/a.css?v=1"><img src="/static/b.png"><a href="https://github.com">x</a>`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<a href="../../">home</a><link href="../../static/a.css?v=1"><img src="../../static/b.png"><a href="https://github.com">x</a>`, string(out))
+
+	out, err = fn("main.css", []byte(`body{}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `body{}`, string(out))
+}
+
+func TestExternalLinks(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Site.URL = "https://example.com"
+	fn := externalLinks(conf, map[string]interface{}{})
+
+	out, err := fn("index.html", []byte(`<a href="https://github.com" rel="nofollow">x</a><a href="https://example.com/a">y</a><script>"<a href=http://x>"</script>`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<a href="https://github.com" rel="nofollow noopener" class="external">x</a><a href="https://example.com/a">y</a><script>"<a href=http://x>"</script>`, string(out))
+}
+
+func TestMinify(t *testing.T) {
+	conf := config.DefaultConfig()
+	fn := minifyTransformer(conf, nil)
+
+	out, err := fn("index.html", []byte("<html>\n  <body>\n    <p>  a  </p>\n  </body>\n</html>"))
+	assert.Nil(t, err)
+	assert.Equal(t, "<html><body><p>a</p></body></html>", string(out))
+
+	out, err = fn("main.css", []byte("body {\n  color: #ffffff;\n}"))
+	assert.Nil(t, err)
+	assert.Equal(t, "body{color:#fff}", string(out))
+
+	// 不支持的文件保持不变
+	out, err = fn("a.txt", []byte("a  b"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a  b", string(out))
+}
+
+func TestLazyImages(t *testing.T) {
+	conf := config.DefaultConfig()
+	conf.Init()
+	conf.Site.URL = "https://example.com"
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3)))
+	open := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}
+	// 源文件不需要已经写入输出目录
+	conf.SetSource("/static/a.png", open)
+	conf.SetSource("/posts/first/b.png", open)
+
+	fn := lazyImages(conf, nil)
+	out, err := fn("posts/first/index.html", []byte(`<img src="https://example.com/static/a.png"><img src="b.png?v=1"><img src="c.png"><img src="a.png" width="10" loading="eager">`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<img src="https://example.com/static/a.png" loading="lazy" width="4" height="3"><img src="b.png?v=1" loading="lazy" width="4" height="3"><img src="c.png" loading="lazy"><img src="a.png" width="10" loading="eager">`, string(out))
+
+	// 每次构建重新记录源文件
+	conf.ResetTransformers()
+	fn = lazyImages(conf, nil)
+	out, err = fn("index.html", []byte(`<img src="/static/a.png">`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<img src="/static/a.png" loading="lazy">`, string(out))
+}
@@ -8,14 +8,18 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gosimple/slug"
 	"github.com/honmaple/snow/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
+var locations sync.Map
+
 type Site struct {
 	URL      string
 	Title    string
@@ -28,7 +32,9 @@ type Config struct {
 	Log   *logrus.Logger
 	Cache *sync.Map
 
-	writer Writer
+	writer       Writer
+	transformers *transformers
+	sources      *sources
 
 	Site            Site
 	OutputDir       string
@@ -121,6 +127,11 @@ func (conf *Config) Write(file string, r io.Reader) error {
 	}
 	output := filepath.Join(conf.OutputDir, file)
 
+	r, err := conf.transform(file, r)
+	if err != nil {
+		return fmt.Errorf("Write %s: %s", output, err.Error())
+	}
+
 	conf.Log.Debugln("Writing", output)
 	if conf.writer != nil {
 		return conf.writer.Write(file, r)
@@ -149,6 +160,36 @@ func (conf *Config) GetHighlightStyle() string {
 	return conf.GetString("content_highlight_style")
 }
 
+// 未设置时区时默认使用UTC, 可以设置为Local或者Asia/Shanghai等
+func (conf *Config) GetTimezone() *time.Location {
+	name := conf.GetString("timezone")
+	if name == "" {
+		return time.UTC
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		conf.Log.Warnf("Unknown timezone %s: %s", name, err.Error())
+		loc = time.UTC
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// ParseTime 使用date_formats和时区解析时间, 只有字符串中没有时区信息时才使用配置的时区, time.Time保持不变
+func (conf *Config) ParseTime(value interface{}) (time.Time, error) {
+	if t, ok := value.(time.Time); ok {
+		return t, nil
+	}
+	s, err := cast.ToStringE(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return utils.ParseTimeInLocation(s, conf.GetStringSlice("date_formats"), conf.GetTimezone())
+}
+
 func (conf *Config) GetSlug(name string) string {
 	if conf.GetBool("slugify") {
 		return slug.Make(name)
@@ -243,6 +284,8 @@ func (conf *Config) Init() {
 	conf.OutputDir = conf.GetString("output_dir")
 	conf.ContentDir = conf.GetString("content_dir")
 	conf.DefaultLanguage = conf.GetString("site.language")
+	conf.transformers = &transformers{}
+	conf.sources = &sources{}
 
 	conf.Languages = make(map[string]*Config)
 	for lang := range conf.GetStringMap("languages") {
@@ -254,6 +297,9 @@ func (conf *Config) Init() {
 			Log:    conf.Log,
 			Cache:  conf.Cache,
 			writer: conf.writer,
+
+			transformers: &transformers{},
+			sources:      conf.sources,
 		}
 		langc.MergeConfigMap(conf.AllSettings())
 		for _, ignore := range conf.GetStringSlice("languages." + lang + ".ignores") {
@@ -313,6 +359,9 @@ var (
 		"slugify":                   true,
 		"formats.rss.template":      "_internal/partials/rss.xml",
 		"formats.atom.template":     "_internal/partials/atom.xml",
+		"ipynb_hide_input_tags":     []string{"hide-input", "hide_input"},
+		"ipynb_hide_output_tags":    []string{"hide-output", "hide_output"},
+		"ipynb_hide_cell_tags":      []string{"remove-cell", "remove_cell"},
 	}
 	// 默认需要修改的配置
 	siteConfig = map[string]interface{}{
@@ -325,6 +374,7 @@ var (
 		"theme.override": "layouts",
 		"output_dir":     "output",
 		"content_dir":    "content",
+		"data_dir":       "data",
 	}
 )
 
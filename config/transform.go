@@ -0,0 +1,207 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/honmaple/snow/utils"
+	"github.com/spf13/cast"
+)
+
+type (
+	Transformer        func(file string, data []byte) ([]byte, error)
+	TransformerCreator func(Config, map[string]interface{}) Transformer
+
+	transformer struct {
+		name  string
+		paths []string
+		types []string
+		fn    Transformer
+	}
+	transformers struct {
+		mu     sync.Mutex
+		list   []*transformer
+		loaded bool
+	}
+	// 输出文件对应的源文件, 所有语言共用
+	sources struct {
+		mu    sync.RWMutex
+		files map[string]func() (io.ReadCloser, error)
+	}
+)
+
+var (
+	_transformers   = make(map[string]TransformerCreator)
+	_transformersMu sync.RWMutex
+)
+
+// 没有设置paths和types时匹配所有文件, paths中不包含"/"时只匹配文件名
+func (t *transformer) match(file string) bool {
+	if len(t.paths) == 0 && len(t.types) == 0 {
+		return true
+	}
+	file = filepath.ToSlash(file)
+	for _, pattern := range t.paths {
+		name := file
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(file)
+		}
+		if ok, _ := path.Match(pattern, strings.TrimPrefix(name, "/")); ok {
+			return true
+		}
+	}
+	typ := utils.MediaType(file)
+	for _, v := range t.types {
+		if v == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// output_transformers:
+//   - name: "minify"
+//     paths: ["*.html", "static/*.css"]
+//   - name: "external_links"
+//     types: ["text/html"]
+//     class: "external"
+func (ts *transformers) load(conf *Config) []*transformer {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.loaded {
+		return ts.list
+	}
+	ts.loaded = true
+
+	_transformersMu.RLock()
+	defer _transformersMu.RUnlock()
+
+	for _, item := range cast.ToSlice(conf.Get("output_transformers")) {
+		opts := cast.ToStringMap(item)
+		name := cast.ToString(opts["name"])
+
+		c, ok := _transformers[name]
+		if !ok {
+			conf.Log.Warnf("The transformer %s not found", name)
+			continue
+		}
+		ts.list = append(ts.list, &transformer{
+			name:  name,
+			paths: cast.ToStringSlice(opts["paths"]),
+			types: cast.ToStringSlice(opts["types"]),
+			fn:    c(*conf, opts),
+		})
+	}
+	return ts.list
+}
+
+func (ts *transformers) reset() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.list = nil
+	ts.loaded = false
+}
+
+func sourceKey(file string) string {
+	return path.Clean("/" + filepath.ToSlash(file))
+}
+
+func (s *sources) set(file string, open func() (io.ReadCloser, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.files == nil {
+		s.files = make(map[string]func() (io.ReadCloser, error))
+	}
+	s.files[sourceKey(file)] = open
+}
+
+func (s *sources) open(file string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	open, ok := s.files[sourceKey(file)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return open()
+}
+
+func (s *sources) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files = nil
+}
+
+// SetSource 记录输出文件对应的源文件, 输出处理时可以读取源文件(比如图片大小)
+func (conf *Config) SetSource(file string, open func() (io.ReadCloser, error)) {
+	if conf.sources == nil {
+		return
+	}
+	conf.sources.set(file, open)
+}
+
+// OpenSource 打开输出文件对应的源文件, 不依赖文件是否已经写入
+func (conf *Config) OpenSource(file string) (io.ReadCloser, error) {
+	if conf.sources == nil {
+		return nil, fs.ErrNotExist
+	}
+	return conf.sources.open(file)
+}
+
+// ResetTransformers 每次构建前重新创建输出处理, 缓存(比如图片大小)只在一次构建中有效
+func (conf *Config) ResetTransformers() {
+	for _, c := range conf.Languages {
+		if c.transformers != nil {
+			c.transformers.reset()
+		}
+	}
+	if conf.transformers != nil {
+		conf.transformers.reset()
+	}
+	if conf.sources != nil {
+		conf.sources.reset()
+	}
+}
+
+func (conf *Config) transform(file string, r io.Reader) (io.Reader, error) {
+	if conf.transformers == nil {
+		return r, nil
+	}
+	list := make([]*transformer, 0)
+	for _, t := range conf.transformers.load(conf) {
+		if t.match(file) {
+			list = append(list, t)
+		}
+	}
+	if len(list) == 0 {
+		return r, nil
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range list {
+		data, err = t.fn(file, data)
+		if err != nil {
+			return nil, fmt.Errorf("transformer %s: %s", t.name, err.Error())
+		}
+	}
+	return bytes.NewReader(data), nil
+}
+
+func RegisterTransformer(name string, c TransformerCreator) {
+	_transformersMu.Lock()
+	defer _transformersMu.Unlock()
+
+	_transformers[name] = c
+}
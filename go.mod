@@ -6,6 +6,7 @@ require (
 	github.com/alecthomas/chroma v0.7.3
 	github.com/bep/golibsass v1.1.1
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
+	github.com/evanw/esbuild v0.17.19
 	github.com/flosch/pongo2/v6 v6.0.0
 	github.com/fsnotify/fsnotify v1.5.4
 	github.com/gosimple/slug v1.13.1
@@ -15,12 +16,14 @@ require (
 	github.com/panjf2000/ants/v2 v2.7.1
 	github.com/pelletier/go-toml/v2 v2.0.8
 	github.com/russross/blackfriday/v2 v2.0.1
+	github.com/shurcooL/sanitized_anchor_name v1.0.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/spf13/cast v1.5.0
 	github.com/spf13/viper v1.13.0
 	github.com/stretchr/testify v1.8.3
 	github.com/tdewolff/minify/v2 v2.12.4
 	github.com/urfave/cli/v2 v2.3.0
+	golang.org/x/crypto v0.0.0-20220411220226-7b82a4e95df4
 	golang.org/x/net v0.10.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var DataFormats = []string{".yaml", ".yml", ".toml", ".json", ".csv"}
+
+// ReadDataFile 读取yaml, toml, json或者csv格式的数据文件, csv文件的第一行作为字段名
+func ReadDataFile(file string) (interface{}, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = UnmarshalYAML(buf, &result)
+	case ".toml":
+		err = toml.Unmarshal(buf, &result)
+	case ".json":
+		err = json.Unmarshal(buf, &result)
+	case ".csv":
+		result, err = readCSV(buf)
+	default:
+		return nil, fmt.Errorf("unknown data format %s", filepath.Ext(file))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UnmarshalYAML 和yaml.Unmarshal相同, 但时间保持为字符串,
+// 否则没有时区的时间和明确指定UTC的时间都会解析为UTC, 无法再使用配置的时区
+func UnmarshalYAML(buf []byte, v interface{}) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf, &node); err != nil {
+		return err
+	}
+	var walk func(*yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!timestamp" {
+			n.Tag = "!!str"
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(&node)
+	return node.Decode(v)
+}
+
+func readCSV(buf []byte) ([]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(buf))
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0)
+	if len(records) == 0 {
+		return result, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{})
+		for i, name := range header {
+			if i < len(record) {
+				row[strings.TrimSpace(name)] = record[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
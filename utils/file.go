@@ -2,10 +2,13 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"mime"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -58,3 +61,70 @@ func FileBaseName(file string) string {
 	file = filepath.Base(file)
 	return file[:len(file)-len(filepath.Ext(file))]
 }
+
+// ReadFileLines 读取文件的部分内容, lines格式为 "10-42", "10-", "-42" 或者 "10",
+// tag表示只读取 "tag::name[]" 和 "end::name[]" 之间的内容
+func ReadFileLines(file string, lines string, tag string) (string, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	result := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	if tag != "" {
+		start, end := -1, -1
+		for i, line := range result {
+			if start < 0 && strings.Contains(line, "tag::"+tag+"[]") {
+				start = i + 1
+			} else if start >= 0 && strings.Contains(line, "end::"+tag+"[]") {
+				end = i
+				break
+			}
+		}
+		if start < 0 || end < 0 {
+			return "", fmt.Errorf("%s: tag %s not found", file, tag)
+		}
+		result = result[start:end]
+	}
+	if lines != "" {
+		start, end, err := parseLineRange(lines, len(result))
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", file, err.Error())
+		}
+		result = result[start-1 : end]
+	}
+	return strings.Join(result, "\n") + "\n", nil
+}
+
+func parseLineRange(lines string, count int) (int, int, error) {
+	var (
+		start = 1
+		end   = count
+		err   error
+	)
+	s := strings.SplitN(lines, "-", 2)
+	if v := strings.TrimSpace(s[0]); v != "" {
+		if start, err = strconv.Atoi(v); err != nil {
+			return 0, 0, fmt.Errorf("invalid lines %s", lines)
+		}
+	}
+	if len(s) == 1 {
+		end = start
+	} else if v := strings.TrimSpace(s[1]); v != "" {
+		if end, err = strconv.Atoi(v); err != nil {
+			return 0, 0, fmt.Errorf("invalid lines %s", lines)
+		}
+	}
+	if start < 1 || end > count || start > end {
+		return 0, 0, fmt.Errorf("lines %s out of range, the file has %d lines", lines, count)
+	}
+	return start, end, nil
+}
+
+// index.html -> text/html
+func MediaType(file string) string {
+	typ := mime.TypeByExtension(filepath.Ext(file))
+	if idx := strings.Index(typ, ";"); idx >= 0 {
+		typ = typ[:idx]
+	}
+	return strings.TrimSpace(typ)
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// "2 4-6" -> [[2,2],[4,6]]
+func parseLines(s string) [][2]int {
+	ranges := make([][2]int, 0)
+	for _, field := range strings.Fields(strings.ReplaceAll(s, ",", " ")) {
+		start, end := field, field
+		if idx := strings.Index(field, "-"); idx > 0 {
+			start, end = field[:idx], field[idx+1:]
+		}
+		a, err1 := strconv.Atoi(start)
+		b, err2 := strconv.Atoi(end)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ranges = append(ranges, [2]int{a, b})
+	}
+	return ranges
+}
+
+// Highlight 使用chroma高亮代码, style为空时只输出转义后的代码
+// opts支持linenos(true|table), hl_lines("2 4-6")和linenostart
+func Highlight(code, lang, style string, opts map[string]string) (string, error) {
+	if style == "" {
+		return fmt.Sprintf("<pre><code class=\"language-%s\">%s</code></pre>", lang, html.EscapeString(code)), nil
+	}
+
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	s := styles.Get(style)
+	if s == nil {
+		s = styles.Fallback
+	}
+
+	options := make([]chromahtml.Option, 0)
+	switch opts["linenos"] {
+	case "", "false":
+	case "table":
+		options = append(options, chromahtml.WithLineNumbers(true), chromahtml.LineNumbersInTable(true))
+	default:
+		options = append(options, chromahtml.WithLineNumbers(true))
+	}
+	if v := opts["hl_lines"]; v != "" {
+		options = append(options, chromahtml.HighlightLines(parseLines(v)))
+	}
+	if v, err := strconv.Atoi(opts["linenostart"]); err == nil {
+		options = append(options, chromahtml.BaseLineNumber(v))
+	}
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return "", err
+	}
+	var w strings.Builder
+	if err := chromahtml.New(options...).Format(&w, s, iterator); err != nil {
+		return "", err
+	}
+	return w.String(), nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLines(t *testing.T) {
+	assert.Equal(t, [][2]int{{2, 2}, {4, 6}}, parseLines("2 4-6"))
+	assert.Equal(t, [][2]int{{1, 3}}, parseLines("1-3,x"))
+}
+
+func TestHighlight(t *testing.T) {
+	out, err := Highlight("a < b", "go", "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `<pre><code class="language-go">a &lt; b</code></pre>`, out)
+
+	out, err = Highlight("package main", "go", "monokai", map[string]string{"linenos": "table"})
+	assert.Nil(t, err)
+	assert.Contains(t, out, "<table")
+	assert.Contains(t, out, "package")
+}
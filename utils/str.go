@@ -2,20 +2,53 @@ package utils
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 )
 
-var DateFormats = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+var (
+	DateFormats = []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05 -0700",
+		"2006/01/02 15:04:05",
+		"2006/01/02 15:04",
+		"2006/01/02",
+		time.RFC1123Z,
+		time.RFC1123,
+	}
+	// orgmode时间戳: <2023-01-02 Mon 10:00>, [2023-01-02 一 10:00-12:00 +1w]
+	ORG_TIMESTAMP = regexp.MustCompile(`^[<\[](\d{4}-\d{2}-\d{2})(?:\s+[^\s\d<>\[\]+-]+)?(?:\s+(\d{1,2}:\d{2})(?:-\d{1,2}:\d{2})?)?(?:\s+[.+-]+\d+[hdwmy])*\s*[>\]]$`)
+)
 
 func ParseTime(value string) (time.Time, error) {
-	for _, f := range DateFormats {
-		// 需要和yaml时间解析保持一致
-		if date, err := time.Parse(f, value); err == nil {
-			return date, nil
+	return ParseTimeInLocation(value, nil, time.UTC)
+}
+
+// ParseTimeInLocation 优先使用自定义的layouts解析时间, 没有时区信息的时间使用loc
+func ParseTimeInLocation(value string, layouts []string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if match := ORG_TIMESTAMP.FindStringSubmatch(value); match != nil {
+		value = strings.TrimSpace(match[1] + " " + match[2])
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, fs := range [][]string{layouts, DateFormats} {
+		for _, f := range fs {
+			// 需要和yaml时间解析保持一致
+			if date, err := time.ParseInLocation(f, value, loc); err == nil {
+				return date, nil
+			}
 		}
 	}
-	return time.Time{}, fmt.Errorf("date format error")
+	return time.Time{}, fmt.Errorf("date format error: %s", value)
 }
 
 func StringConcat(strs ...string) string {